@@ -0,0 +1,531 @@
+package gosql
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/tkdeng/goutil"
+)
+
+// AllowTable adds a table to the allowlist of the policy
+//
+// Once a policy has an allowlist, every table and column referenced by a query
+// must be in it, or the query will fail the "allowlist" rule.
+// This catches probing of tables like `sqlite_master` or `information_schema`,
+// which looks like a normal query to the other rules.
+//
+// @columns: the columns a query may reference.
+// If no columns are listed, every column of the table is allowed.
+// Once a query reads a table with listed columns, the columns of subqueries
+// (and of other tables that are not listed) must be qualified, like `sub.name`,
+// and column aliases can only be used as a term of ORDER BY.
+//
+// Note: `PRAGMA` and `ATTACH` statements are denied once a policy has an allowlist.
+//
+// Note: unlike custom checks, the allowlist is not inherited from the default policy.
+func (policy *SafetyPolicy) AllowTable(name string, columns ...string) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+
+	if policy.allowed == nil {
+		policy.allowed = newTableList()
+	}
+
+	if len(columns) == 0 {
+		policy.allowed.set(name, nil)
+	} else {
+		policy.allowed.set(name, append([]string{}, columns...))
+	}
+}
+
+// allowTables adds the table list of a database to the allowlist
+func (policy *SafetyPolicy) allowTables(tables *tableList) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+
+	for _, list := range policy.schemas {
+		if list == tables {
+			return
+		}
+	}
+
+	// checks may still hold the list returned by `allowlist`
+	policy.schemas = slices.Concat(policy.schemas, []*tableList{tables})
+}
+
+// allowlist returns the table lists of the allowlist, or nil if any table is allowed
+func (policy *SafetyPolicy) allowlist() []*tableList {
+	policy.mu.RLock()
+	defer policy.mu.RUnlock()
+
+	if policy.allowed == nil {
+		return policy.schemas
+	}
+	return append([]*tableList{policy.allowed}, policy.schemas...)
+}
+
+// EnforceAllowlist will only allow queries to reference the tables and columns
+// registered with `db.Table`
+//
+// Tables registered after this method is called are also allowed,
+// and dropped tables are removed from the allowlist.
+//
+// @introspect: if true, the tables and columns that already exist in the database
+// will also be allowed, so they can be used without passing columns to `db.Table`.
+// They are only added to the safety policy, so the tables known to the database
+// (and the order `Set` writes their columns in) do not change.
+//
+// Note: the allowlist is added to the safety policy of the database, and a query can
+// skip it with `query.AllowUnsafe(reason, "allowlist")`.
+func (db *DB) EnforceAllowlist(introspect ...bool) error {
+	if len(introspect) != 0 && introspect[0] {
+		schema, err := db.schema()
+		if err != nil {
+			return err
+		}
+
+		existing := newTableList()
+		existing.replace(schema)
+		db.policy.allowTables(existing)
+	}
+
+	db.policy.allowTables(db.tables)
+	return nil
+}
+
+// schema reads the existing tables of the database, and the order of their columns
+func (db *DB) schema() (map[string][]string, error) {
+	q := `SELECT m.name, p.name FROM sqlite_master m JOIN pragma_table_info(m.name) p ` +
+		`WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%' ORDER BY m.name, p.cid`
+	if db.dialect == MySQL {
+		q = `SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS ` +
+			`WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION`
+	}
+
+	rows, err := db.SQL.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := map[string][]string{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		schema[table] = append(schema[table], column)
+	}
+	return schema, rows.Err()
+}
+
+// allowlistKeywords are words that are never read as a table or column name
+var allowlistKeywords = []string{
+	"ABORT", "ALTER", "BEGIN", "BINARY", "BLOB", "BOOLEAN", "CHAR", "COLLATE", "COMMIT", "CONFLICT",
+	"CREATE", "CROSS", "CURRENT", "CURRENT_DATE", "CURRENT_TIME", "CURRENT_TIMESTAMP", "DATE", "DATETIME",
+	"DECIMAL", "DEFAULT", "DIV", "DO", "DOUBLE", "DROP", "DUPLICATE", "ESCAPE", "EXCEPT", "EXPLAIN", "FAIL",
+	"FALSE", "FILTER", "FIRST", "FLOAT", "FOLLOWING", "FOR", "FULL", "GLOB", "IF", "IGNORE", "INDEX",
+	"INDEXED", "INNER", "INT", "INTEGER", "INTERSECT", "INTERVAL", "ISNULL", "KEY", "LAST", "LATERAL",
+	"LEFT", "LOCK", "MATCH", "MOD", "MODE", "NATURAL", "NOTHING", "NOTNULL", "NOWAIT", "NULLS", "NUMERIC",
+	"OF", "OFFSET", "ONLY", "OUTER", "OVER", "PARTITION", "PLAN", "PRAGMA", "PRECEDING", "PRIMARY", "QUERY", "RANGE",
+	"REAL", "RECURSIVE", "REFERENCES", "REGEXP", "REPLACE", "RETURNING", "RIGHT", "ROLLBACK", "ROW", "ROWS",
+	"SHARE", "SIGNED", "STRAIGHT_JOIN", "TABLE", "TEXT", "TIME", "TRUE", "TRUNCATE", "UNBOUNDED", "UNIQUE",
+	"UNSIGNED", "USING", "VARCHAR", "VIEW", "WINDOW", "WITH", "XOR",
+}
+
+// allowlistTableKeywords may come between a keyword like INTO and the name of a table
+var allowlistTableKeywords = []string{
+	"ABORT", "EXISTS", "FAIL", "IF", "IGNORE", "LATERAL", "NOT", "ONLY", "OR", "REPLACE", "ROLLBACK",
+}
+
+// allowlistStatements are the statements that have their columns checked
+var allowlistStatements = []string{"DELETE", "INSERT", "REPLACE", "SELECT", "UPDATE", "VALUES", "WITH"}
+
+// allowlistDenied are statements that can read the schema, or other databases,
+// without naming a table
+var allowlistDenied = []string{"ATTACH", "PRAGMA"}
+
+// allowlistOrderBy are the words that may follow a term of ORDER BY
+var allowlistOrderBy = []string{"ASC", "COLLATE", "DESC", "FIRST", "LAST", "NULLS"}
+
+// implicitColumns are columns every sqlite table has
+var implicitColumns = []string{"ROWID", "OID", "_ROWID_"}
+
+// allowlistScope is the state of a parenthesis while reading the tables of a query
+type allowlistScope struct {
+	start    int
+	derived  bool
+	fromList bool
+}
+
+// allowlistAlias is a column alias, and the index of the parenthesis it was defined in
+type allowlistAlias struct {
+	name  string
+	scope int
+}
+
+// checkAllowlist denies tables and columns that are not in the allowlist
+//
+// common sql injection: `' UNION SELECT sql FROM sqlite_master` to read the schema.
+//
+// @dialect: sqlite reads a double quoted string as a column name, if a column has that name.
+func checkAllowlist(query string, tokens []token, lists []*tableList, dialect Dialect) *UnsafeQueryError {
+	// comments are denied by another rule, but that rule may be skipped
+	list := make([]token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.kind != tokenComment {
+			list = append(list, tok)
+		}
+	}
+	tokens = list
+
+	lookup := func(name string) ([]string, bool) {
+		for _, list := range lists {
+			if columns, ok := list.lookup(name); ok {
+				return columns, true
+			}
+		}
+		return nil, false
+	}
+
+	isPunct := func(i int, text string) bool {
+		return i < len(tokens) && tokens[i].kind == tokenPunct && tokens[i].text == text
+	}
+
+	isName := func(i int) bool {
+		if i >= len(tokens) {
+			return false
+		}
+		kw := tokens[i].keyword()
+		return tokens[i].kind == tokenIdent || (tokens[i].kind == tokenWord &&
+			!goutil.Contains(sqlKeywords, kw) && !goutil.Contains(allowlistKeywords, kw))
+	}
+
+	// isColumn is like isName, but also returns true for the strings sqlite may read as a name:
+	// `"column"` if a column has that name, and `'table'.column`
+	isColumn := func(i int) bool {
+		if isName(i) {
+			return true
+		}
+		return i < len(tokens) && tokens[i].kind == tokenString && dialect == SQLite &&
+			(strings.HasPrefix(tokens[i].text, `"`) || isPunct(i+1, "."))
+	}
+
+	// name reads a name like `table` or `schema.table`, and returns its parts
+	// and the index of the next token
+	//
+	// sqlite reads a string as a name where a name is expected, like `FROM "sqlite_master"`,
+	// so the parts may also be strings.
+	name := func(i int) ([]string, int) {
+		parts := []string{strings.ToLower(tokens[i].value())}
+		i++
+		for isPunct(i, ".") && i+1 < len(tokens) && (tokens[i+1].kind == tokenWord || tokens[i+1].kind == tokenIdent || tokens[i+1].kind == tokenString) {
+			parts = append(parts, strings.ToLower(tokens[i+1].value()))
+			i += 2
+		}
+		return parts, i
+	}
+
+	// closing returns the index after the parenthesis opened at i
+	closing := func(i int) int {
+		depth := 0
+		for ; i < len(tokens); i++ {
+			if isPunct(i, "(") {
+				depth++
+			} else if isPunct(i, ")") {
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	}
+
+	// endsValue returns true if the token at i can be followed by an implicit alias
+	endsValue := func(i int) bool {
+		if i < 0 {
+			return false
+		}
+		switch tokens[i].kind {
+		case tokenNumber, tokenString, tokenParam, tokenIdent:
+			return true
+		case tokenPunct:
+			return tokens[i].text == ")"
+		}
+		return isName(i)
+	}
+
+	// sources are the tables and aliases a column can be qualified with
+	// (nil columns allow any column)
+	sources := map[string][]string{}
+
+	// tables are the allowed columns of every table the query reads, that does not allow any column
+	tables := [][]string{}
+
+	// aliases are the column aliases of the query
+	aliases := []allowlistAlias{}
+
+	// read is true for tokens that are already known as a table or alias
+	read := make([]bool, len(tokens))
+
+	addSource := func(name string, columns []string) {
+		sources[name] = columns
+		if columns != nil {
+			tables = append(tables, columns)
+		}
+	}
+
+	// alias reads an optional `AS alias` or `alias` at i, and returns the index of the next token
+	alias := func(i int, columns []string) int {
+		if i < len(tokens) && tokens[i].keyword() == "AS" && (isName(i+1) || (i+1 < len(tokens) && tokens[i+1].kind == tokenString)) {
+			i++
+		} else if !isName(i) && !isColumn(i) {
+			return i
+		}
+
+		sources[strings.ToLower(tokens[i].value())] = columns
+		read[i] = true
+		return i + 1
+	}
+
+	scopes := []allowlistScope{}
+	current := func() int {
+		if len(scopes) == 0 {
+			return -1
+		}
+		return scopes[len(scopes)-1].start
+	}
+
+	expectTable, fromList, insert := false, false, false
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		kw := tok.keyword()
+
+		switch {
+		case isPunct(i, "("):
+			scopes = append(scopes, allowlistScope{start: i, derived: expectTable, fromList: fromList})
+			expectTable, fromList = false, false
+
+		case isPunct(i, ")"):
+			if len(scopes) == 0 {
+				continue
+			}
+			scope := scopes[len(scopes)-1]
+			scopes = scopes[:len(scopes)-1]
+			fromList = scope.fromList
+
+			if scope.derived {
+				// the columns of a subquery are not known, so they must be qualified with its alias
+				next := alias(i+1, nil)
+				if fromList && isPunct(next, ",") {
+					expectTable = true
+					next++
+				}
+				i = next - 1
+			}
+
+		case expectTable && goutil.Contains(allowlistTableKeywords, kw):
+			// INSERT OR REPLACE INTO, CREATE TABLE IF NOT EXISTS
+
+		case expectTable && (isName(i) || tok.kind == tokenString):
+			// a quoted table name is checked the same way, once its quotes are removed
+			parts, next := name(i)
+			columns, ok := lookup(strings.Join(parts, "."))
+			if !ok {
+				if _, ok = sources[strings.Join(parts, ".")]; !ok || len(parts) != 1 {
+					return unsafeTokens("allowlist", "table is not allowed", query, tokens[i:next])
+				}
+				// common table expression
+				columns = nil
+			}
+
+			for j := i; j < next; j++ {
+				read[j] = true
+			}
+			addSource(strings.Join(parts, "."), columns)
+			sources[parts[len(parts)-1]] = columns
+			if insert {
+				// sqlite upsert: ON CONFLICT DO UPDATE SET name = excluded.name
+				sources["excluded"] = columns
+				insert = false
+			}
+
+			expectTable = false
+			next = alias(next, columns)
+			if fromList && isPunct(next, ",") {
+				expectTable = true
+				next++
+			}
+			i = next - 1
+
+		case tok.kind == tokenWord && !isName(i):
+			expectTable, fromList, insert = false, false, false
+
+			switch kw {
+			case "FROM", "JOIN":
+				expectTable, fromList = true, true
+			case "INTO", "TABLE":
+				expectTable = true
+				insert = kw == "INTO"
+			case "UPDATE":
+				// not ON DUPLICATE KEY UPDATE, DO UPDATE or FOR UPDATE
+				expectTable = i == 0 || !goutil.Contains([]string{"DO", "KEY", "FOR"}, tokens[i-1].keyword())
+			case "AS":
+				if isName(i+1) || (i+1 < len(tokens) && tokens[i+1].kind == tokenString) {
+					aliases = append(aliases, allowlistAlias{strings.ToLower(tokens[i+1].value()), current()})
+					read[i+1] = true
+					i++
+				}
+			case "COLLATE":
+				// the name of a collation, like NOCASE
+				if i+1 < len(tokens) {
+					read[i+1] = true
+					i++
+				}
+			}
+
+		case isColumn(i):
+			parts, next := name(i)
+
+			// common table expression: name AS (...) or name (columns) AS (...)
+			end := next
+			if isPunct(next, "(") {
+				end = closing(next)
+			}
+			if len(parts) == 1 && end < len(tokens) && tokens[end].keyword() == "AS" && isPunct(end+1, "(") {
+				sources[parts[0]] = nil
+				for j := i; j < end; j++ {
+					read[j] = true
+				}
+				i = end - 1
+				continue
+			}
+
+			if len(parts) == 1 && endsValue(i-1) {
+				// implicit alias: SELECT count(*) total
+				aliases = append(aliases, allowlistAlias{parts[0], current()})
+				read[i] = true
+				continue
+			}
+			i = next - 1
+		}
+	}
+
+	hasColumn := func(columns []string, column string) bool {
+		if goutil.Contains(implicitColumns, strings.ToUpper(column)) {
+			return true
+		}
+		for _, col := range columns {
+			if strings.EqualFold(col, column) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// isAlias returns true if the name at i is a whole term of ORDER BY, and an alias of the same query.
+	// A column alias is not allowed anywhere else, because sqlite reads names in WHERE, GROUP BY
+	// and HAVING as columns first, so `SELECT name AS password ... WHERE password` reads the password.
+	isAlias := func(i int, next int, column string, scope int, orderBy bool) bool {
+		if !orderBy || !(isPunct(i-1, ",") || tokens[i-1].keyword() == "BY") ||
+			!(next == len(tokens) || isPunct(next, ",") || isPunct(next, ")") || isPunct(next, ";") ||
+				goutil.Contains(allowlistOrderBy, tokens[next].keyword()) ||
+				tokens[next].keyword() == "LIMIT" || tokens[next].keyword() == "OFFSET") {
+			return false
+		}
+		for _, alias := range aliases {
+			if alias.name == column && alias.scope == scope {
+				return true
+			}
+		}
+		return false
+	}
+
+	// each statement of the query is checked on its own
+	checked := false
+	parens := []int{}
+	orderBy := map[int]bool{}
+
+	for i := 0; i < len(tokens); i++ {
+		if i == 0 || isPunct(i-1, ";") {
+			// EXPLAIN QUERY PLAN PRAGMA ...
+			j := i
+			for j < len(tokens) && goutil.Contains([]string{"EXPLAIN", "QUERY", "PLAN"}, tokens[j].keyword()) {
+				j++
+			}
+			if j < len(tokens) && goutil.Contains(allowlistDenied, tokens[j].keyword()) {
+				return unsafeTokens("allowlist", "statement is not allowed", query, tokens[j:j+1])
+			}
+			checked = j < len(tokens) && goutil.Contains(allowlistStatements, tokens[j].keyword())
+			parens, orderBy = parens[:0], map[int]bool{}
+		}
+
+		scope := -1
+		if len(parens) != 0 {
+			scope = parens[len(parens)-1]
+		}
+
+		if isPunct(i, "(") {
+			parens = append(parens, i)
+			continue
+		} else if isPunct(i, ")") {
+			if len(parens) != 0 {
+				parens = parens[:len(parens)-1]
+			}
+			continue
+		} else if tokens[i].kind == tokenWord && !isName(i) && !read[i] {
+			// a keyword other than the ones that may follow a term ends ORDER BY
+			if kw := tokens[i].keyword(); kw == "BY" && i > 0 && tokens[i-1].keyword() == "ORDER" {
+				orderBy[scope] = true
+			} else if kw != "ORDER" && !goutil.Contains(allowlistOrderBy, kw) {
+				orderBy[scope] = false
+			}
+			continue
+		}
+
+		if !checked || read[i] || !isColumn(i) {
+			continue
+		}
+
+		parts, next := name(i)
+		if isPunct(next, "(") {
+			// function call
+			i = next - 1
+			continue
+		}
+
+		if isPunct(next, ".") {
+			// table.*
+			parts = append(parts, "*")
+		}
+
+		if len(parts) == 1 {
+			// if every table allows any column, the column may be from any of them,
+			// or from a subquery
+			ok := len(tables) == 0 || isAlias(i, next, parts[0], scope, orderBy[scope])
+			for _, columns := range tables {
+				if ok {
+					break
+				}
+				ok = hasColumn(columns, parts[0])
+			}
+			if !ok {
+				return unsafeTokens("allowlist", "column is not allowed", query, tokens[i:next])
+			}
+		} else {
+			column := parts[len(parts)-1]
+			columns, ok := sources[strings.Join(parts[:len(parts)-1], ".")]
+			if !ok {
+				return unsafeTokens("allowlist", "table is not allowed", query, tokens[i:next])
+			}
+			if column != "*" && columns != nil && !hasColumn(columns, column) {
+				return unsafeTokens("allowlist", "column is not allowed", query, tokens[i:next])
+			}
+		}
+		i = next - 1
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package gosql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupOption is an optional setting of `db.Backup` and `db.Restore`
+type BackupOption func(opts *backupOptions)
+
+type backupOptions struct {
+	pages    int
+	pause    time.Duration
+	progress func(remaining int, total int)
+}
+
+// BackupStep copies the database a few pages at a time, instead of all at once
+//
+// Between each step, the database is unlocked, so other connections can write to it.
+// If the database changes during the backup, the backup will start over.
+//
+// @pages: the number of pages to copy in each step
+//
+// @pause: the time to wait between steps
+func BackupStep(pages int, pause time.Duration) BackupOption {
+	return func(opts *backupOptions) {
+		opts.pages = pages
+		opts.pause = max(pause, 0)
+	}
+}
+
+// BackupProgress calls cb after each step of a backup, with the number of pages left to copy
+func BackupProgress(cb func(remaining int, total int)) BackupOption {
+	return func(opts *backupOptions) {
+		opts.progress = cb
+	}
+}
+
+func newBackupOptions(opts []BackupOption) *backupOptions {
+	o := &backupOptions{pages: -1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.pages <= 0 {
+		o.pages = -1
+	}
+	return o
+}
+
+// Backup copies an sqlite database to a file, while the database is still in use
+//
+// An existing file at destPath is replaced.
+//
+// The database is copied with `VACUUM INTO` to a temporary file next to destPath,
+// which then replaces destPath, so the progress callback is not called.
+//
+// If gosql is built with `-tags gosql_sqlite3`, databases that use the go-sqlite3 driver
+// are copied with the sqlite online backup api instead.
+// The tag links go-sqlite3 into gosql, so it is not the default.
+//
+// Note: the backup holds one connection of the pool until it is done,
+// so other statements will wait for it if the database only has one connection.
+//
+// @destPath: the path of the backup file
+//
+// @opts: optional settings, like `BackupStep(n, pause)` or `BackupProgress(cb)`
+func (db *DB) Backup(ctx context.Context, destPath string, opts ...BackupOption) error {
+	if db.remote != nil {
+		return errRemoteSQL
+	} else if db.dialect != SQLite {
+		return errors.New("backup is only supported by sqlite databases")
+	}
+
+	ok, err := onlineBackup(ctx, db, destPath, false, newBackupOptions(opts))
+	if ok || err != nil {
+		return err
+	}
+	return db.vacuumInto(ctx, destPath)
+}
+
+// vacuumInto copies the database to a file with `VACUUM INTO`
+//
+// `VACUUM INTO` only writes to a new (or empty) file, so the copy is written
+// to a temporary file, which replaces destPath once it is done.
+func (db *DB) vacuumInto(ctx context.Context, destPath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+
+	// `VACUUM INTO` does not modify the database, so it passes the read only checks
+	if _, err := db.SQL.ExecContext(ctx, `VACUUM INTO ?`, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), destPath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Restore replaces the content of an sqlite database with a backup file
+//
+// The backup is attached to a connection of the database, and its tables, indexes,
+// views and triggers replace the ones of the database in a single transaction.
+//
+// If gosql is built with `-tags gosql_sqlite3` (see `db.Backup`), databases that use
+// the go-sqlite3 driver are restored with the sqlite online backup api instead.
+//
+// Other connections of the pool will see the restored database once the copy is done.
+//
+// The tables known to the database (and its allowlist, see `db.EnforceAllowlist`) are
+// replaced by the tables of the backup, so `db.Table` will create the tables it lacks.
+//
+// @srcPath: the path of a backup file, like one made by `db.Backup`
+//
+// @opts: optional settings, like `BackupStep(n, pause)` or `BackupProgress(cb)`.
+// They are only used by the online backup api.
+func (db *DB) Restore(srcPath string, opts ...BackupOption) error {
+	if db.remote != nil {
+		return errRemoteSQL
+	} else if db.dialect != SQLite {
+		return errors.New("restore is only supported by sqlite databases")
+	} else if db.readOnly {
+		return Error_ReadOnly
+	}
+
+	ctx := context.Background()
+	ok, err := onlineBackup(ctx, db, srcPath, true, newBackupOptions(opts))
+	if err != nil {
+		return err
+	} else if !ok {
+		if err := db.restoreAttached(ctx, srcPath); err != nil {
+			return err
+		}
+	}
+
+	schema, err := db.schema()
+	if err != nil {
+		return err
+	}
+	db.tables.replace(schema)
+	return nil
+}
+
+// restoreSchema is the name the backup is attached as by `restoreAttached`
+const restoreSchema = "gosql_restore"
+
+// restoreAttached replaces the content of the database with an attached backup file
+//
+// The statements run on the driver connection, so they skip the checks of a safe driver,
+// like the online backup api.
+func (db *DB) restoreAttached(ctx context.Context, srcPath string) error {
+	// the backup is opened read only, so a missing file is not created
+	dsn, err := (&options{sqlite: SQLiteOptions{Cache: "private", Mode: "ro"}}).sqliteDSN(srcPath)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.SQL.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		if safe, isSafe := driverConn.(*safeConn); isSafe {
+			driverConn = safe.base
+		}
+
+		raw, ok := driverConn.(rawConn)
+		if !ok {
+			return errors.New("restore is not supported by the sqlite driver")
+		}
+
+		if err := rawExec(ctx, raw, `ATTACH DATABASE ? AS `+restoreSchema, dsn); err != nil {
+			return err
+		}
+		defer rawExec(context.Background(), raw, `DETACH DATABASE `+restoreSchema)
+
+		if err := rawExec(ctx, raw, `BEGIN IMMEDIATE`); err != nil {
+			return err
+		}
+		if err := copyAttached(ctx, raw); err != nil {
+			rawExec(context.Background(), raw, `ROLLBACK`)
+			return err
+		}
+		return rawExec(ctx, raw, `COMMIT`)
+	})
+}
+
+// copyAttached drops the schema of the main database, and copies the schema
+// and rows of the attached backup, inside a transaction
+func copyAttached(ctx context.Context, raw rawConn) error {
+	// foreign keys are checked once the transaction commits
+	if err := rawExec(ctx, raw, `PRAGMA defer_foreign_keys = ON`); err != nil {
+		return err
+	}
+
+	// views and triggers are dropped first, since they may use the tables
+	old, err := rawQuery(ctx, raw, `SELECT type, name FROM main.sqlite_master `+
+		`WHERE type IN ('table', 'view', 'trigger') AND name NOT LIKE 'sqlite_%' ORDER BY type = 'table'`)
+	if err != nil {
+		return err
+	}
+	for _, row := range old {
+		if err := rawExec(ctx, raw, `DROP `+strings.ToUpper(row[0])+` IF EXISTS main.`+quoteIdent(row[1])); err != nil {
+			return err
+		}
+	}
+
+	// tables are created (and filled) before indexes, views and triggers
+	schema, err := rawQuery(ctx, raw, `SELECT type, name, sql FROM `+restoreSchema+`.sqlite_master `+
+		`WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY type <> 'table', rowid`)
+	if err != nil {
+		return err
+	}
+	for _, row := range schema {
+		// the sql of the backup creates the objects in the main database
+		if err := rawExec(ctx, raw, row[2]); err != nil {
+			return err
+		}
+		if row[0] == "table" {
+			table := quoteIdent(row[1])
+			if err := rawExec(ctx, raw, `INSERT INTO main.`+table+` SELECT * FROM `+restoreSchema+`.`+table); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the next AUTOINCREMENT ids, which are kept when their tables are dropped
+	sequence, err := rawQuery(ctx, raw, `SELECT name FROM main.sqlite_master WHERE name = 'sqlite_sequence'`)
+	if err != nil || len(sequence) == 0 {
+		return err
+	}
+	if err := rawExec(ctx, raw, `DELETE FROM main.sqlite_sequence`); err != nil {
+		return err
+	}
+
+	sequence, err = rawQuery(ctx, raw, `SELECT name FROM `+restoreSchema+`.sqlite_master WHERE name = 'sqlite_sequence'`)
+	if err != nil || len(sequence) == 0 {
+		return err
+	}
+	return rawExec(ctx, raw, `INSERT INTO main.sqlite_sequence SELECT * FROM `+restoreSchema+`.sqlite_sequence`)
+}
+
+// rawConn is a driver connection that can run statements without preparing them
+type rawConn interface {
+	driver.ExecerContext
+	driver.QueryerContext
+}
+
+// rawArgs converts the args of a statement to driver args
+func rawArgs(args []any) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// rawExec runs a statement on a driver connection
+func rawExec(ctx context.Context, conn rawConn, query string, args ...any) error {
+	_, err := conn.ExecContext(ctx, query, rawArgs(args))
+	return err
+}
+
+// rawQuery reads the rows of a statement on a driver connection, as text
+func rawQuery(ctx context.Context, conn rawConn, query string, args ...any) ([][]string, error) {
+	rows, err := conn.QueryContext(ctx, query, rawArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := [][]string{}
+	values := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err := rows.Next(values); err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(values))
+		for i, val := range values {
+			switch val := val.(type) {
+			case string:
+				row[i] = val
+			case []byte:
+				row[i] = string(val)
+			}
+		}
+		list = append(list, row)
+	}
+}
+
+// quoteIdent quotes an sqlite identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,105 @@
+//go:build cgo && gosql_sqlite3
+
+// The online backup api links the go-sqlite3 driver, which also registers the "sqlite3" driver,
+// so it is only built with `-tags gosql_sqlite3`.
+
+package gosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// onlineBackupSupported is true if the sqlite online backup api is built in
+const onlineBackupSupported = true
+
+// onlineBackup copies the database to a file (or a file to the database if restore is true)
+// with the sqlite online backup api
+//
+// ok is false if the database does not use the go-sqlite3 driver.
+func onlineBackup(ctx context.Context, db *DB, path string, restore bool, o *backupOptions) (ok bool, err error) {
+	conn, err := db.SQL.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		if safe, isSafe := driverConn.(*safeConn); isSafe {
+			driverConn = safe.base
+		}
+
+		dbConn, isSQLite := driverConn.(*sqlite3.SQLiteConn)
+		if !isSQLite {
+			return nil
+		}
+		ok = true
+
+		// the file must exist to be restored
+		file := &options{sqlite: SQLiteOptions{Cache: "private"}}
+		if restore {
+			file.sqlite.Mode = "ro"
+		}
+
+		dsn, err := file.sqliteDSN(path)
+		if err != nil {
+			return err
+		}
+
+		fileConn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer fileConn.Close()
+
+		src, dest := dbConn, fileConn.(*sqlite3.SQLiteConn)
+		if restore {
+			src, dest = dest, src
+		}
+
+		backup, err := dest.Backup("main", src, "main")
+		if err != nil {
+			return err
+		}
+		return runBackup(ctx, backup, o)
+	})
+
+	return ok, err
+}
+
+// runBackup copies the pages of a backup, until it is done or the context is canceled
+func runBackup(ctx context.Context, backup *sqlite3.SQLiteBackup, o *backupOptions) error {
+	for {
+		// a busy or locked database is not an error, and the step is tried again
+		done, err := backup.Step(o.pages)
+		if err != nil {
+			backup.Close()
+			return err
+		}
+
+		if o.progress != nil {
+			o.progress(backup.Remaining(), backup.PageCount())
+		}
+
+		if done {
+			return backup.Finish()
+		}
+
+		pause := o.pause
+		if pause == 0 && o.pages == -1 {
+			// every page is copied in one step, so the database was busy
+			pause = time.Millisecond * 10
+		}
+
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			backup.Close()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
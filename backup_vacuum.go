@@ -0,0 +1,13 @@
+//go:build !cgo || !gosql_sqlite3
+
+package gosql
+
+import "context"
+
+// onlineBackupSupported is true if the sqlite online backup api is built in
+const onlineBackupSupported = false
+
+// onlineBackup is only built with `-tags gosql_sqlite3`, so backups use `VACUUM INTO`
+func onlineBackup(ctx context.Context, db *DB, path string, restore bool, o *backupOptions) (ok bool, err error) {
+	return false, nil
+}
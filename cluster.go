@@ -0,0 +1,217 @@
+package gosql
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer chooses the replica a read query will run on
+type Balancer uint8
+
+const (
+	// RoundRobin reads from each healthy replica in turn
+	RoundRobin Balancer = iota
+
+	// LeastLatency reads from the healthy replica with the lowest average query time
+	LeastLatency
+)
+
+// DefaultHealthCheckInterval is how often a failing replica is pinged, to check if it is back up
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// ReplicaStats describes the state of a replica
+type ReplicaStats struct {
+	Healthy bool
+
+	// Latency is the moving average time of queries that ran on the replica
+	Latency time.Duration
+}
+
+// cluster is the list of replicas shared by copies of a DB
+type cluster struct {
+	mu       sync.RWMutex
+	balancer Balancer
+	interval time.Duration
+
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// replica is a database that read queries can be routed to
+type replica struct {
+	db *DB
+
+	healthy  atomic.Bool
+	checking atomic.Bool
+	checked  atomic.Int64
+	latency  atomic.Int64
+}
+
+// OpenCluster returns a database, which routes read queries to its replicas
+//
+// `Get`, `Has` and `Count` run on a replica, chosen by the balancer (see `db.SetBalancer`).
+// Writes, transactions, raw queries and queries with `query.Primary()` run on the primary.
+//
+// A replica is removed from the rotation when a query on it fails, and it can not be pinged.
+// It will be pinged again every health check interval (see `db.SetHealthCheck`),
+// and added back once it responds.
+//
+// The hooks, safety policy and table list of the primary are used for every query,
+// including queries that run on a replica.
+//
+// Note: `db.Close` will also close the replicas.
+func OpenCluster(primary *DB, replicas ...*DB) (*DB, error) {
+	if primary == nil {
+		return nil, errors.New("cluster has no primary database")
+	}
+
+	c := &cluster{
+		balancer: RoundRobin,
+		interval: DefaultHealthCheckInterval,
+	}
+
+	for _, db := range replicas {
+		r := &replica{db: db}
+		r.healthy.Store(db.SQL.Ping() == nil)
+		r.checked.Store(time.Now().UnixNano())
+		c.replicas = append(c.replicas, r)
+	}
+
+	db := *primary
+	db.cluster = c
+	return &db, nil
+}
+
+// SetBalancer chooses how read queries are spread across the replicas of a cluster
+//
+// default: RoundRobin
+func (db *DB) SetBalancer(balancer Balancer) {
+	if db.cluster == nil {
+		return
+	}
+
+	db.cluster.mu.Lock()
+	defer db.cluster.mu.Unlock()
+
+	db.cluster.balancer = balancer
+}
+
+// SetHealthCheck sets how often a failing replica is pinged, to check if it is back up
+//
+// default: 10 seconds
+func (db *DB) SetHealthCheck(interval time.Duration) {
+	if db.cluster == nil {
+		return
+	}
+
+	db.cluster.mu.Lock()
+	defer db.cluster.mu.Unlock()
+
+	db.cluster.interval = interval
+}
+
+// Replicas returns the state of each replica, in the order they were added with `OpenCluster`
+func (db *DB) Replicas() []ReplicaStats {
+	if db.cluster == nil {
+		return nil
+	}
+
+	stats := make([]ReplicaStats, len(db.cluster.replicas))
+	for i, r := range db.cluster.replicas {
+		stats[i] = ReplicaStats{
+			Healthy: r.healthy.Load(),
+			Latency: time.Duration(r.latency.Load()),
+		}
+	}
+	return stats
+}
+
+// Primary will run the query on the primary database, instead of a replica
+//
+// Use this to read a row right after it was written, since replicas may lag behind.
+func (query Query) Primary() *Query {
+	query.primary = true
+	return &query
+}
+
+// replica returns the replica a statement should run on, or nil to use the primary
+func (query *Query) replica(op Op) *replica {
+	if op != OpSelect || query.primary || query.tx != nil || query.db.cluster == nil {
+		return nil
+	}
+	return query.db.cluster.pick()
+}
+
+// pick chooses a healthy replica, or returns nil if every replica is down
+func (c *cluster) pick() *replica {
+	c.mu.RLock()
+	balancer, interval := c.balancer, c.interval
+	c.mu.RUnlock()
+
+	if len(c.replicas) == 0 {
+		return nil
+	}
+
+	var best *replica
+	start := int(c.next.Add(1) % uint64(len(c.replicas)))
+	for i := range c.replicas {
+		r := c.replicas[(start+i)%len(c.replicas)]
+		if !r.healthy.Load() {
+			r.recheck(interval)
+			continue
+		}
+
+		if balancer == RoundRobin {
+			return r
+		}
+		if best == nil || r.latency.Load() < best.latency.Load() {
+			best = r
+		}
+	}
+
+	return best
+}
+
+// fail checks a replica after a query on it failed
+//
+// @down: returns true if the replica can not be pinged, and was removed from the rotation
+func (r *replica) fail() (down bool) {
+	if r.db.SQL.Ping() == nil {
+		return false
+	}
+
+	r.healthy.Store(false)
+	r.checked.Store(time.Now().UnixNano())
+	return true
+}
+
+// recheck pings a failing replica in the background, once the health check interval passed
+func (r *replica) recheck(interval time.Duration) {
+	if time.Since(time.Unix(0, r.checked.Load())) < interval || !r.checking.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer r.checking.Store(false)
+
+		r.healthy.Store(r.db.SQL.Ping() == nil)
+		r.checked.Store(time.Now().UnixNano())
+	}()
+}
+
+// observe adds the duration of a query to the moving average latency of the replica
+func (r *replica) observe(d time.Duration) {
+	if old := r.latency.Load(); old != 0 {
+		d = (time.Duration(old)*7 + d) / 8
+	}
+	r.latency.Store(int64(d))
+}
+
+// closeReplicas closes the replicas of a cluster
+func (c *cluster) closeReplicas() {
+	for _, r := range c.replicas {
+		r.db.Close()
+	}
+}
@@ -0,0 +1,58 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+)
+
+// CredentialProvider returns the credentials of a database server
+//
+// It is called before each new connection (see `Server.Credentials`).
+type CredentialProvider interface {
+	// Credentials returns the username and password to connect with
+	//
+	// An empty username will keep the `Server.Username`.
+	Credentials(ctx context.Context) (username string, password string, err error)
+}
+
+// CredentialFunc is a CredentialProvider built from a callback
+type CredentialFunc func(ctx context.Context) (username string, password string, err error)
+
+// Credentials calls the callback
+func (fn CredentialFunc) Credentials(ctx context.Context) (string, string, error) {
+	return fn(ctx)
+}
+
+// EnvCredentials reads the username and password from environment variables
+//
+// @usernameVar: can be empty, to keep the `Server.Username`
+func EnvCredentials(usernameVar string, passwordVar string) CredentialProvider {
+	return CredentialFunc(func(ctx context.Context) (string, string, error) {
+		password, ok := os.LookupEnv(passwordVar)
+		if !ok {
+			return "", "", errors.New("environment variable is not set: " + passwordVar)
+		}
+
+		if usernameVar == "" {
+			return "", password, nil
+		}
+		return os.Getenv(usernameVar), password, nil
+	})
+}
+
+// FileCredentials reads the password from a file, like a mounted secret that rotates
+//
+// Trailing new lines are removed from the password.
+//
+// @username: can be empty, to keep the `Server.Username`
+func FileCredentials(username string, passwordFile string) CredentialProvider {
+	return CredentialFunc(func(ctx context.Context) (string, string, error) {
+		password, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", "", err
+		}
+		return username, strings.TrimRight(string(password), "\r\n"), nil
+	})
+}
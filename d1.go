@@ -0,0 +1,392 @@
+package gosql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultD1Endpoint is the base url of the cloudflare api
+const DefaultD1Endpoint = "https://api.cloudflare.com/client/v4"
+
+// D1Config is the config of a cloudflare D1 database
+type D1Config struct {
+	AccountID  string
+	DatabaseID string
+
+	// Token is a cloudflare api token, with D1 read and edit permissions
+	Token string
+
+	// Endpoint is the base url of the api (default: DefaultD1Endpoint)
+	Endpoint string
+
+	// Client is the http client used for requests (default: http.DefaultClient)
+	Client *http.Client
+}
+
+// D1Error is an error returned by the D1 api
+type D1Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *D1Error) Error() string {
+	return "d1: " + err.Message + " (" + strconv.Itoa(err.Code) + ")"
+}
+
+// errD1Pending is returned by the result of a statement, which waits for its transaction to commit
+var errD1Pending = errors.New("d1: the result of a statement is not known until its transaction commits")
+
+// OpenD1 opens a cloudflare D1 database, with the D1 http api
+//
+// The database uses the sqlite dialect.
+//
+// D1 does not support interactive transactions over http, so the statements of a
+// transaction (see `db.Begin`) are sent together as a batch when it commits,
+// and D1 runs the batch as a single transaction.
+// Queries that return rows are not batched, and will not see the pending writes of the transaction.
+//
+// @opts: optional settings, like `ReadOnly()`, `MaxOpenConns(n)` or `LazyConnect()`
+func OpenD1(config D1Config, opts ...Option) (*DB, error) {
+	if config.AccountID == "" || config.DatabaseID == "" {
+		return nil, errors.New("d1: account id and database id are required")
+	}
+
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultD1Endpoint
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	return newDB(sql.OpenDB(&d1Connector{config: config}), SQLite, newOptions(opts), false)
+}
+
+// d1Statement is a statement sent to the D1 api
+type d1Statement struct {
+	SQL    string `json:"sql"`
+	Params []any  `json:"params"`
+}
+
+// d1Response is the response of the D1 raw query endpoint
+type d1Response struct {
+	Success bool       `json:"success"`
+	Errors  []*D1Error `json:"errors"`
+	Result  []struct {
+		Results struct {
+			Columns []string `json:"columns"`
+			Rows    [][]any  `json:"rows"`
+		} `json:"results"`
+		Meta struct {
+			Changes   int64 `json:"changes"`
+			LastRowID int64 `json:"last_row_id"`
+		} `json:"meta"`
+	} `json:"result"`
+}
+
+// d1Connector opens connections to a D1 database
+type d1Connector struct {
+	config D1Config
+}
+
+func (c *d1Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &d1Conn{config: &c.config}, nil
+}
+
+func (c *d1Connector) Driver() driver.Driver {
+	return d1Driver{}
+}
+
+// d1Driver can only be opened with `OpenD1`
+type d1Driver struct{}
+
+func (d1Driver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("d1: use gosql.OpenD1 to open a D1 database")
+}
+
+// d1Conn is a connection to a D1 database
+//
+// Each statement is an http request, so the connection itself holds no state,
+// except for the statements of a transaction.
+type d1Conn struct {
+	config *D1Config
+
+	inTx  bool
+	batch []d1Statement
+}
+
+// request sends a statement, or a batch of statements, to the D1 api
+func (c *d1Conn) request(ctx context.Context, body any) (*d1Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.config.Endpoint + "/accounts/" + c.config.AccountID + "/d1/database/" + c.config.DatabaseID + "/raw"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// numbers are decoded as json.Number, to keep integers exact
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+
+	var resp d1Response
+	if err := dec.Decode(&resp); err != nil && err != io.EOF {
+		return nil, errors.New("d1: invalid response (" + res.Status + "): " + err.Error())
+	}
+
+	if !resp.Success || res.StatusCode >= 300 {
+		if len(resp.Errors) != 0 {
+			return nil, resp.Errors[0]
+		}
+		return nil, errors.New("d1: request failed: " + res.Status)
+	}
+
+	return &resp, nil
+}
+
+// statement builds a statement with the args of a query
+func (c *d1Conn) statement(query string, args []driver.NamedValue) (d1Statement, error) {
+	st := d1Statement{SQL: query, Params: make([]any, len(args))}
+	for i, arg := range args {
+		if arg.Name != "" {
+			return st, errors.New("d1: named args are not supported")
+		}
+
+		switch val := arg.Value.(type) {
+		case []byte:
+			st.Params[i] = string(val)
+		case time.Time:
+			st.Params[i] = val.Format(time.RFC3339Nano)
+		default:
+			st.Params[i] = val
+		}
+	}
+	return st, nil
+}
+
+func (c *d1Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	st, err := c.statement(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.inTx {
+		c.batch = append(c.batch, st)
+		return d1PendingResult{}, nil
+	}
+
+	resp, err := c.request(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &d1Result{}
+	if len(resp.Result) != 0 {
+		result.lastID = resp.Result[0].Meta.LastRowID
+		result.changes = resp.Result[0].Meta.Changes
+	}
+	return result, nil
+}
+
+func (c *d1Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	st, err := c.statement(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.request(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := &d1Rows{}
+	if len(resp.Result) != 0 {
+		rows.columns = resp.Result[0].Results.Columns
+		rows.rows = resp.Result[0].Results.Rows
+	}
+	return rows, nil
+}
+
+func (c *d1Conn) Ping(ctx context.Context) error {
+	_, err := c.request(ctx, d1Statement{SQL: "SELECT 1", Params: []any{}})
+	return err
+}
+
+func (c *d1Conn) Prepare(query string) (driver.Stmt, error) {
+	return &d1Stmt{conn: c, query: query}, nil
+}
+
+func (c *d1Conn) Close() error {
+	return nil
+}
+
+func (c *d1Conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *d1Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.inTx {
+		return nil, errors.New("d1: transaction already started")
+	}
+
+	c.inTx = true
+	c.batch = nil
+	return &d1Tx{conn: c}, nil
+}
+
+// d1Tx sends the statements of a transaction as a batch when it commits
+type d1Tx struct {
+	conn *d1Conn
+}
+
+func (tx *d1Tx) Commit() error {
+	batch := tx.conn.batch
+	tx.conn.inTx, tx.conn.batch = false, nil
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	_, err := tx.conn.request(context.Background(), map[string]any{"batch": batch})
+	return err
+}
+
+func (tx *d1Tx) Rollback() error {
+	tx.conn.inTx, tx.conn.batch = false, nil
+	return nil
+}
+
+// d1Stmt is a prepared statement, which is sent with every request
+type d1Stmt struct {
+	conn  *d1Conn
+	query string
+}
+
+func (st *d1Stmt) Close() error {
+	return nil
+}
+
+func (st *d1Stmt) NumInput() int {
+	return -1
+}
+
+func (st *d1Stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return st.conn.ExecContext(context.Background(), st.query, namedValues(args))
+}
+
+func (st *d1Stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return st.conn.QueryContext(context.Background(), st.query, namedValues(args))
+}
+
+func (st *d1Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return st.conn.ExecContext(ctx, st.query, args)
+}
+
+func (st *d1Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return st.conn.QueryContext(ctx, st.query, args)
+}
+
+// namedValues converts positional args to named values
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// d1Result is the result of a statement
+type d1Result struct {
+	lastID  int64
+	changes int64
+}
+
+func (res *d1Result) LastInsertId() (int64, error) {
+	return res.lastID, nil
+}
+
+func (res *d1Result) RowsAffected() (int64, error) {
+	return res.changes, nil
+}
+
+// d1PendingResult is the result of a statement in a transaction, before it commits
+type d1PendingResult struct{}
+
+func (d1PendingResult) LastInsertId() (int64, error) {
+	return 0, errD1Pending
+}
+
+func (d1PendingResult) RowsAffected() (int64, error) {
+	return 0, errD1Pending
+}
+
+// d1Rows are the rows returned by a query
+type d1Rows struct {
+	columns []string
+	rows    [][]any
+	i       int
+}
+
+func (rows *d1Rows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *d1Rows) Close() error {
+	return nil
+}
+
+func (rows *d1Rows) Next(dest []driver.Value) error {
+	if rows.i >= len(rows.rows) {
+		return io.EOF
+	}
+
+	for i, val := range rows.rows[rows.i] {
+		if i < len(dest) {
+			dest[i] = d1Value(val)
+		}
+	}
+	rows.i++
+	return nil
+}
+
+// d1Value converts a json value to a driver value
+//
+// Integers are read as int64 and other numbers as float64.
+// Blobs are read from a list of bytes.
+func d1Value(val any) driver.Value {
+	switch val := val.(type) {
+	case json.Number:
+		return jsonValue(val)
+	case []any:
+		blob := make([]byte, len(val))
+		for i, b := range val {
+			if n, ok := b.(json.Number); ok {
+				v, _ := n.Int64()
+				blob[i] = byte(v)
+			}
+		}
+		return blob
+	case map[string]any:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+	return val
+}
@@ -0,0 +1,26 @@
+package gosql
+
+import (
+	"strings"
+)
+
+// Dialect is the flavor of SQL spoken by a database driver
+type Dialect string
+
+const (
+	SQLite Dialect = "sqlite"
+	MySQL  Dialect = "mysql"
+)
+
+// dialectOf returns the dialect of a `database/sql` driver name
+func dialectOf(driverName string) Dialect {
+	if strings.Contains(strings.ToLower(driverName), "mysql") {
+		return MySQL
+	}
+	return SQLite
+}
+
+// Dialect returns the SQL dialect of the database
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
@@ -1,7 +1,7 @@
 package gosql
 
 import (
-	"strings"
+	"database/sql"
 )
 
 // Get will SELECT keys FROM table, and run a loop over the selected rows
@@ -10,70 +10,84 @@ import (
 //   - return true, to continue the loop
 //   - return false, to close the query and break the loop
 func (query *Query) Get(keys []string, cb func(scan func(dest ...any) error) bool) error {
-	q := `SELECT `
-	if len(keys) == 0 {
-		q += `*`
-	} else {
-		for i := 0; i < len(keys); i++ {
-			q += toAlphaNumeric(keys[i])
-			if i != len(keys)-1 {
-				q += `, `
-			}
-		}
+	q, args := query.SelectSQL(keys)
+
+	if query.db.remote != nil {
+		return query.runRemote(OpSelect, q, args, &Statement{Method: MethodGet, Keys: keys}, func(row []any) bool {
+			return cb(func(dest ...any) error {
+				return scanRow(row, dest)
+			})
+		})
 	}
 
-	q += ` FROM ` + query.table
+	return query.each(OpSelect, q, args, func(rows *sql.Rows) bool {
+		return cb(rows.Scan)
+	})
+}
 
-	if query.where != "" {
-		q += ` ` + query.where
-	}
+// Count will SELECT COUNT(*) FROM table, and return the number of rows that match the where query
+func (query *Query) Count() (int, error) {
+	q, args := query.CountSQL()
 
-	if query.order != "" {
-		q += ` ` + query.order
-	}
+	count := 0
+	var scanErr error
 
-	rows, err := query.db.Query(q, query.whereValue...)
-	if err != nil {
-		return err
+	if query.db.remote != nil {
+		err := query.runRemote(OpSelect, q, args, &Statement{Method: MethodCount}, func(row []any) bool {
+			scanErr = scanRow(row, []any{&count})
+			return false
+		})
+		if err != nil {
+			return 0, err
+		}
+		return count, scanErr
 	}
 
-	for rows.Next() {
-		if !cb(rows.Scan) {
-			break
-		}
+	err := query.each(OpSelect, q, args, func(rows *sql.Rows) bool {
+		scanErr = rows.Scan(&count)
+		return false
+	})
+	if err != nil {
+		return 0, err
 	}
-	rows.Close()
 
-	return nil
+	return count, scanErr
 }
 
 // Has will check if key value pairs are found in the database (using SELECT WHERE)
 //
 // If a row is found, this method will return true.
 // If nothing is found, or an error occurs, this method will return false.
+//
+// Keys are sorted the same way as the `Set` method.
 func (query *Query) Has(values map[string]any) bool {
+	return query.HasValues(query.OrderValues(values))
+}
+
+// HasValues will check if an ordered list of key value pairs are found in the database
+//
+// This method works the same as `Has`, but keeps the order of the values.
+func (query *Query) HasValues(values Values) bool {
 	if len(values) == 0 {
 		return false
 	}
 
-	valList := []any{}
+	q, args := query.HasSQL(values)
 
-	q := `SELECT * FROM ` + query.table + ` WHERE `
-	for key, val := range values {
-		q += toAlphaNumeric(key) + ` = ? AND `
-		valList = append(valList, val)
-	}
-	q = q[:len(q)-5]
+	found := false
 
-	if query.where != "" {
-		q += ` AND` + strings.TrimPrefix(query.where, "WHERE")
-		valList = append(valList, query.whereValue...)
+	if query.db.remote != nil {
+		err := query.runRemote(OpSelect, q, args, &Statement{Method: MethodHas, Values: values}, func(row []any) bool {
+			found = scanRow(row, []any{&found}) == nil && found
+			return false
+		})
+		return err == nil && found
 	}
 
-	if rows, err := query.db.Query(q, valList...); err == nil && rows.Next() {
-		rows.Close()
-		return true
-	}
+	err := query.each(OpSelect, q, args, func(rows *sql.Rows) bool {
+		found = true
+		return false
+	})
 
-	return false
+	return err == nil && found
 }
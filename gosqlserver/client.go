@@ -0,0 +1,85 @@
+package gosqlserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/tkdeng/gosql"
+)
+
+// Client runs the statements of a remote database on a server
+type Client struct {
+	// URL is the url of the server handler
+	URL string
+
+	// Token is the bearer token sent with every request
+	Token string
+
+	// HTTP is the http client used for requests (default: http.DefaultClient)
+	HTTP *http.Client
+}
+
+// Dial opens a remote database, which runs the query builder on a server
+//
+// @url: the url of the server handler
+//
+// @opts: optional settings, like `gosql.ReadOnly()`
+func Dial(url string, token string, opts ...gosql.Option) (*gosql.DB, error) {
+	return gosql.OpenRemote(&Client{URL: url, Token: token}, opts...)
+}
+
+// Run sends a statement to the server, and calls cb for every row it returns
+//
+// Errors returned by the server are an `*Error`, which matches
+// `gosql.Error_UnsafeQuery` and `gosql.Error_ReadOnly` with `errors.Is`.
+func (client *Client) Run(ctx context.Context, stmt *gosql.Statement, cb func(row []any) bool) error {
+	data, err := json.Marshal(stmt)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+client.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := client.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// numbers are decoded as json.Number, to keep integers exact
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+
+	var resp response
+	if err := dec.Decode(&resp); err != nil && err != io.EOF {
+		return errors.New("gosqlserver: invalid response (" + res.Status + "): " + err.Error())
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if res.StatusCode >= 300 {
+		return errors.New("gosqlserver: request failed: " + res.Status)
+	}
+
+	for _, row := range resp.Rows {
+		if !cb(row) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Package gosqlserver shares a gosql database over http
+//
+// Clients send the operations of the query builder as json (see `gosql.Statement`),
+// instead of raw sql, so the server builds every query itself,
+// and checks it with its own safety policy.
+package gosqlserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkdeng/gosql"
+)
+
+// maxBodySize is the maximum size of a request body
+const maxBodySize = 1 << 20
+
+// DefaultMaxRows is the default number of rows a server will return for a single statement
+const DefaultMaxRows = 10000
+
+// DefaultMaxResponseSize is the default size in bytes of the rows a server will return for a single statement
+const DefaultMaxResponseSize = 16 << 20
+
+// Server is an http handler, which runs the statements of remote databases
+type Server struct {
+	db     *gosql.DB
+	tokens [][]byte
+
+	// AllowForceDelete allows clients to delete every row of a table,
+	// with a Delete that has no where query
+	AllowForceDelete bool
+
+	// MaxRows is the maximum number of rows returned for a statement (0 for no limit).
+	// A statement that reads more rows fails with a "limit" error.
+	MaxRows int
+
+	// MaxResponseSize is the maximum size of the json rows returned for a statement (0 for no limit).
+	// A statement that reads more fails with a "limit" error.
+	MaxResponseSize int
+}
+
+// New returns a server, which shares a database with clients that have one of the tokens
+//
+// The server has its own copy of the database safety policy, which only allows
+// the tables and columns that exist in the database, or are registered with `db.Table`
+// (see `db.EnforceAllowlist`). The safety policy and tables of the original database
+// are not changed.
+//
+// To deny writes, pass a read only database (see `db.ReadOnly`).
+//
+// @tokens: the bearer tokens a client can use. If no tokens are given, every request is denied.
+func New(db *gosql.DB, tokens ...string) (*Server, error) {
+	db = db.WithSafetyPolicy(db.SafetyPolicy().Clone())
+	if err := db.EnforceAllowlist(true); err != nil {
+		return nil, err
+	}
+
+	server := &Server{db: db, MaxRows: DefaultMaxRows, MaxResponseSize: DefaultMaxResponseSize}
+	for _, token := range tokens {
+		if token != "" {
+			server.tokens = append(server.tokens, []byte(token))
+		}
+	}
+
+	return server, nil
+}
+
+// SafetyPolicy returns the safety policy of the server
+//
+// Changes to this policy do not affect the original database.
+func (server *Server) SafetyPolicy() *gosql.SafetyPolicy {
+	return server.db.SafetyPolicy()
+}
+
+// Error is an error returned by the server
+type Error struct {
+	// Kind is the type of error: "auth", "request", "unsafe", "read_only", "limit" or "query"
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+
+	// Rule, Fragment and Pos describe the safety rule an unsafe statement failed
+	Rule     string `json:"rule,omitempty"`
+	Fragment string `json:"fragment,omitempty"`
+	Pos      int    `json:"pos,omitempty"`
+}
+
+func (err *Error) Error() string {
+	if unwrap := err.Unwrap(); unwrap != nil {
+		return unwrap.Error()
+	}
+	return "gosqlserver: " + err.Message
+}
+
+// Unwrap returns a `*gosql.UnsafeQueryError` for unsafe statements,
+// and `gosql.Error_ReadOnly` for writes to a read only database
+func (err *Error) Unwrap() error {
+	switch err.Kind {
+	case "unsafe":
+		return &gosql.UnsafeQueryError{Rule: err.Rule, Message: err.Message, Fragment: err.Fragment, Pos: err.Pos}
+	case "read_only":
+		return gosql.Error_ReadOnly
+	}
+	return nil
+}
+
+// newError converts an error from the database to a server error
+func newError(err error) *Error {
+	var unsafe *gosql.UnsafeQueryError
+	if errors.As(err, &unsafe) {
+		return &Error{Kind: "unsafe", Message: unsafe.Message, Rule: unsafe.Rule, Fragment: unsafe.Fragment, Pos: unsafe.Pos}
+	}
+
+	if errors.Is(err, gosql.Error_UnsafeQuery) {
+		return &Error{Kind: "unsafe", Message: "query is unsafe", Rule: "unsafe"}
+	}
+
+	if errors.Is(err, gosql.Error_ReadOnly) {
+		return &Error{Kind: "read_only", Message: err.Error()}
+	}
+
+	return &Error{Kind: "query", Message: err.Error()}
+}
+
+// response is the json body of a server response
+type response struct {
+	Rows  [][]any `json:"rows,omitempty"`
+	Error *Error  `json:"error,omitempty"`
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		server.reply(w, http.StatusMethodNotAllowed, &response{Error: &Error{Kind: "request", Message: "method not allowed"}})
+		return
+	}
+
+	if !server.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		server.reply(w, http.StatusUnauthorized, &response{Error: &Error{Kind: "auth", Message: "invalid token"}})
+		return
+	}
+
+	// numbers are decoded as json.Number, to keep integers exact
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.UseNumber()
+
+	var stmt gosql.Statement
+	if err := dec.Decode(&stmt); err != nil {
+		server.reply(w, http.StatusBadRequest, &response{Error: &Error{Kind: "request", Message: "invalid statement: " + err.Error()}})
+		return
+	}
+
+	if stmt.Method == gosql.MethodDelete && stmt.Force && !server.AllowForceDelete {
+		stmt.Force = false
+	}
+
+	res := &response{}
+	size := 0
+	var limit *Error
+	err := server.db.Run(&stmt, func(row []any) bool {
+		for i, val := range row {
+			switch v := val.(type) {
+			case []byte:
+				row[i] = string(v)
+			case time.Time:
+				row[i] = v.Format(time.RFC3339Nano)
+			}
+		}
+
+		if server.MaxRows > 0 && len(res.Rows) >= server.MaxRows {
+			limit = &Error{Kind: "limit", Message: "statement returned more than " + strconv.Itoa(server.MaxRows) + " rows"}
+			return false
+		}
+
+		if server.MaxResponseSize > 0 {
+			if b, err := json.Marshal(row); err == nil {
+				size += len(b)
+			}
+			if size > server.MaxResponseSize {
+				limit = &Error{Kind: "limit", Message: "statement returned more than " + strconv.Itoa(server.MaxResponseSize) + " bytes"}
+				return false
+			}
+		}
+
+		res.Rows = append(res.Rows, row)
+		return true
+	})
+	if err == nil && limit != nil {
+		server.reply(w, http.StatusUnprocessableEntity, &response{Error: limit})
+		return
+	} else if err != nil {
+		server.reply(w, http.StatusUnprocessableEntity, &response{Error: newError(err)})
+		return
+	}
+
+	server.reply(w, http.StatusOK, res)
+}
+
+// authorized checks the bearer token of a request
+func (server *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+
+	found := 0
+	for _, t := range server.tokens {
+		found |= subtle.ConstantTimeCompare([]byte(token), t)
+	}
+	return found == 1
+}
+
+// reply writes a json response
+func (server *Server) reply(w http.ResponseWriter, status int, res *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,145 @@
+package gosqlserver
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tkdeng/gosql"
+)
+
+func TestServer(t *testing.T) {
+	db, err := gosql.Open("sqlite3", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	table := db.Table("users", gosql.TEXT("name"), gosql.INT("age"))
+	table.Set(map[string]any{"name": "alice", "age": 30})
+	table.Set(map[string]any{"name": "bob", "age": 20})
+	table.Set(map[string]any{"name": "carol", "age": 40})
+	db.SQL.Exec(`CREATE TABLE legacy (b TEXT, a TEXT)`)
+
+	server, err := New(db, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the original database does not enforce the allowlist, or learn the existing tables
+	if rows, err := db.Query("SELECT name FROM sqlite_master"); err != nil {
+		t.Error("server changed the safety policy of the database:", err)
+	} else {
+		rows.Close()
+	}
+	if values := db.Table("legacy").OrderValues(map[string]any{"a": 1, "b": 2}); values[0].Key != "a" {
+		t.Error("server changed the tables of the database:", values)
+	}
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	denied, _ := Dial(ts.URL, "wrong")
+	var serverErr *Error
+	if _, err := denied.Table("users").Count(); !errors.As(err, &serverErr) || serverErr.Kind != "auth" {
+		t.Error("invalid token was not denied:", err)
+	}
+
+	remote, err := Dial(ts.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	err = remote.Table("users").Where("age").GreaterThan(25).Or("name").Equal("bob").OrderBy("age", true).Get([]string{"name", "age"}, func(scan func(dest ...any) error) bool {
+		var name string
+		var age int
+		if err := scan(&name, &age); err != nil {
+			t.Error(err)
+		}
+		names = append(names, name)
+		return true
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(names) != 3 || names[0] != "carol" || names[2] != "bob" {
+		t.Error("remote rows were not selected in order:", names)
+	}
+
+	if err := remote.Table("users").Set(map[string]any{"name": "dave", "age": 50}); err != nil {
+		t.Error(err)
+	}
+	if !remote.Table("users").Has(map[string]any{"name": "dave"}) {
+		t.Error("remote row was not inserted")
+	}
+
+	if err := remote.Table("users").Where("name").Equal("dave").Set(map[string]any{"age": 51}); err != nil {
+		t.Error(err)
+	}
+	if n, err := remote.Table("users").Where("age").Between(51, 51).Count(); err != nil || n != 1 {
+		t.Error("remote row was not updated:", n, err)
+	}
+
+	if err := remote.Table("users").Where("name").Equal("dave").Delete(); err != nil {
+		t.Error(err)
+	}
+	if n, _ := db.Table("users").Count(); n != 3 {
+		t.Error("remote row was not deleted:", n)
+	}
+
+	// a forced delete is denied by the server
+	if err := remote.Table("users").Delete(true); !errors.Is(err, gosql.Error_UnsafeQuery) {
+		t.Error("forced delete was not denied:", err)
+	}
+	if n, _ := db.Table("users").Count(); n != 3 {
+		t.Error("rows were deleted by a forced delete:", n)
+	}
+
+	// the server only allows known tables and columns
+	var unsafe *gosql.UnsafeQueryError
+	if _, err := remote.Table("secrets").Count(); !errors.As(err, &unsafe) || unsafe.Rule != "allowlist" {
+		t.Error("unknown table was not denied:", err)
+	}
+	if err := remote.Table("users").Get([]string{"password"}, func(scan func(dest ...any) error) bool { return true }); !errors.Is(err, gosql.Error_UnsafeQuery) {
+		t.Error("unknown column was not denied:", err)
+	}
+
+	if _, err := remote.Query("SELECT * FROM users"); err == nil {
+		t.Error("raw sql was run by a remote database")
+	}
+
+	// existing tables are allowed by the server
+	if n, err := remote.Table("legacy").Count(); err != nil || n != 0 {
+		t.Error("existing table was denied:", n, err)
+	}
+
+	// large results are denied, instead of buffered
+	all := func(scan func(dest ...any) error) bool { return true }
+	server.MaxRows = 2
+	if err := remote.Table("users").Get([]string{"name"}, all); !errors.As(err, &serverErr) || serverErr.Kind != "limit" {
+		t.Error("row limit was not enforced:", err)
+	}
+	server.MaxRows = 0
+	server.MaxResponseSize = 16
+	if err := remote.Table("users").Get([]string{"name"}, all); !errors.As(err, &serverErr) || serverErr.Kind != "limit" {
+		t.Error("response size limit was not enforced:", err)
+	}
+	server.MaxResponseSize = DefaultMaxResponseSize
+	if err := remote.Table("users").Get([]string{"name"}, all); err != nil {
+		t.Error(err)
+	}
+
+	readOnly, err := New(db.ReadOnly(), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2 := httptest.NewServer(readOnly)
+	defer ts2.Close()
+
+	remote2, _ := Dial(ts2.URL, "token")
+	if err := remote2.Table("users").Set(map[string]any{"name": "eve"}); !errors.Is(err, gosql.Error_ReadOnly) {
+		t.Error("write to a read only server was not denied:", err)
+	}
+}
@@ -0,0 +1,200 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Op is the type of operation a statement runs
+type Op string
+
+const (
+	OpSelect Op = "SELECT"
+	OpInsert Op = "INSERT"
+	OpUpdate Op = "UPDATE"
+	OpDelete Op = "DELETE"
+	OpCreate Op = "CREATE"
+	OpDrop   Op = "DROP"
+
+	// OpRaw is a raw sql query from `db.Query`, `db.Exec` or `db.Prepare`
+	OpRaw Op = "RAW"
+)
+
+// QueryInfo describes a statement run by the database
+type QueryInfo struct {
+	SQL  string
+	Args []any
+
+	// Table is the name of the table selected by the query builder
+	// (empty for raw queries)
+	Table string
+
+	Op Op
+
+	// Unsafe is true if the statement skipped the safety checks
+	Unsafe bool
+
+	// UnsafeReason is the reason given to `query.AllowUnsafe` or `query.AllowAllUnsafe`
+	UnsafeReason string
+
+	// UnsafeRules are the safety rules allowed by `query.AllowUnsafe`
+	UnsafeRules []string
+
+	// SkippedRules are the allowed safety rules, that the statement actually failed
+	// (set by the time `Before` is called)
+	SkippedRules []string
+
+	// Rows is the number of rows read by the query builder
+	// (only set for read operations, by the time `After` is called)
+	Rows int
+
+	tx *Tx
+
+	// write is true for raw statements from `db.Exec`
+	write bool
+
+	// err denies the statement before it runs, like an invalid `AllowUnsafe`
+	err error
+}
+
+// Hook observes every statement run by the database
+//
+// Hooks are called in the order they were added with `db.Use`.
+type Hook interface {
+	// Before is called before a statement runs
+	//
+	// return an error to veto the statement, and it will be returned to the caller.
+	Before(ctx context.Context, info *QueryInfo) error
+
+	// After is called after a statement finished, or was vetoed
+	//
+	// @result: is only set for operations that do not return rows
+	After(ctx context.Context, info *QueryInfo, result sql.Result, err error, duration time.Duration)
+}
+
+// HookFunc is a Hook built from optional callbacks
+type HookFunc struct {
+	BeforeFunc func(ctx context.Context, info *QueryInfo) error
+	AfterFunc  func(ctx context.Context, info *QueryInfo, result sql.Result, err error, duration time.Duration)
+}
+
+// Before calls BeforeFunc, if it exists
+func (hook HookFunc) Before(ctx context.Context, info *QueryInfo) error {
+	if hook.BeforeFunc != nil {
+		return hook.BeforeFunc(ctx, info)
+	}
+	return nil
+}
+
+// After calls AfterFunc, if it exists
+func (hook HookFunc) After(ctx context.Context, info *QueryInfo, result sql.Result, err error, duration time.Duration) {
+	if hook.AfterFunc != nil {
+		hook.AfterFunc(ctx, info, result, err, duration)
+	}
+}
+
+// hookList is the interceptor chain shared by copies of a DB
+type hookList struct {
+	mu    sync.RWMutex
+	hooks []Hook
+}
+
+func (list *hookList) get() []Hook {
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+
+	return list.hooks
+}
+
+// Use adds hooks to observe every statement run by the database
+//
+// This includes statements that bypass the safety checks (like `Drop`),
+// and raw queries from `db.Query`, `db.Exec` and `db.Prepare`.
+//
+// The safety checks run as the first step of the chain, so a hook can
+// veto a query the same way an unsafe query is denied.
+func (db *DB) Use(hooks ...Hook) {
+	db.hooks.mu.Lock()
+	defer db.hooks.mu.Unlock()
+
+	// statements that are running keep the list they read from `get`
+	db.hooks.hooks = slices.Concat(db.hooks.hooks, hooks)
+}
+
+// run passes a statement through the read only and safety checks and hooks, and calls fn to run it
+func (db *DB) run(ctx context.Context, info *QueryInfo, fn func() (sql.Result, error)) error {
+	hooks := db.hooks.get()
+
+	if info.err != nil {
+		return afterHooks(ctx, hooks, info, nil, info.err, 0)
+	}
+
+	if err := db.checkReadOnly(info); err != nil {
+		return afterHooks(ctx, hooks, info, nil, err, 0)
+	}
+
+	return runHooks(ctx, db.policy, db.dialect, hooks, info, fn)
+}
+
+// runHooks passes a statement through the safety checks and hooks, and calls fn to run it
+//
+// @policy: nil to skip the safety checks, if the statement was already checked
+//
+// @dialect: the dialect of the statement, used by the safety checks
+func runHooks(ctx context.Context, policy *SafetyPolicy, dialect Dialect, hooks []Hook, info *QueryInfo, fn func() (sql.Result, error)) error {
+	if policy != nil && !info.Unsafe {
+		skipped, err := policy.analyze(info.SQL, dialect, info.UnsafeRules)
+		if err != nil {
+			return afterHooks(ctx, hooks, info, nil, err, 0)
+		}
+		info.SkippedRules = skipped
+	}
+
+	for _, hook := range hooks {
+		if err := hook.Before(ctx, info); err != nil {
+			return afterHooks(ctx, hooks, info, nil, err, 0)
+		}
+	}
+
+	start := time.Now()
+	res, err := fn()
+	return afterHooks(ctx, hooks, info, res, err, time.Since(start))
+}
+
+// afterHooks calls the After method of every hook, and returns the error of the statement
+func afterHooks(ctx context.Context, hooks []Hook, info *QueryInfo, res sql.Result, err error, d time.Duration) error {
+	for _, hook := range hooks {
+		hook.After(ctx, info, res, err, d)
+	}
+	return err
+}
+
+// info describes a statement built by the query
+func (query *Query) info(op Op, q string, args []any) *QueryInfo {
+	info := &QueryInfo{
+		SQL:    q,
+		Args:   args,
+		Table:  query.table,
+		Op:     op,
+		Unsafe: query.db.unsafe,
+		tx:     query.tx,
+	}
+
+	if query.unsafe != nil {
+		info.UnsafeReason = query.unsafe.reason
+		info.UnsafeRules = query.unsafe.rules
+		info.err = query.unsafe.err
+		if query.unsafe.all {
+			info.Unsafe = true
+		}
+	}
+
+	if query.err != nil {
+		info.err = query.err
+	}
+
+	return info
+}
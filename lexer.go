@@ -0,0 +1,208 @@
+package gosql
+
+import (
+	"strings"
+)
+
+// tokenKind is the type of an sql token
+type tokenKind uint8
+
+const (
+	// tokenWord is a keyword or an unquoted identifier
+	tokenWord tokenKind = iota
+
+	// tokenIdent is a quoted identifier (`name` or [name])
+	tokenIdent
+
+	// tokenString is a string literal ('text' or "text")
+	tokenString
+
+	tokenNumber
+
+	// tokenParam is a placeholder (?, ?1, :name, @name or $1)
+	tokenParam
+
+	// tokenOperator is an operator (=, <>, <=, ||, +, ...)
+	tokenOperator
+
+	// tokenPunct is one of ( ) , . ;
+	tokenPunct
+
+	// tokenComment is a comment (-- text, # text or /* text */)
+	tokenComment
+)
+
+// token is a single lexeme of an sql query
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+
+	// unterminated is true if a string, identifier or comment was never closed
+	unterminated bool
+
+	// backslash is true if a string can escape characters with a backslash (mysql)
+	backslash bool
+}
+
+// value returns the content of a string or quoted identifier, without the quotes
+func (tok token) value() string {
+	switch tok.kind {
+	case tokenString, tokenIdent:
+		text := tok.text
+		if len(text) >= 2 && !tok.unterminated {
+			text = text[1 : len(text)-1]
+		} else if len(text) >= 1 {
+			text = text[1:]
+		}
+
+		quote := tok.text[:1]
+		text = strings.ReplaceAll(text, quote+quote, quote)
+		if tok.backslash {
+			text = strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`).Replace(text)
+		}
+		return text
+	}
+	return tok.text
+}
+
+// keyword returns the upper case text of a word, or an empty string for any other token
+func (tok token) keyword() string {
+	if tok.kind == tokenWord {
+		return strings.ToUpper(tok.text)
+	}
+	return ""
+}
+
+// tokenize splits an sql query into tokens
+//
+// Whitespace is skipped, but comments are kept, so they can be checked for safety.
+//
+// @dialect: strings can only escape characters with a backslash in mysql.
+// In sqlite (and standard sql), a backslash is a normal character, like in `'C:\'`.
+func tokenize(query string, dialect Dialect) []token {
+	tokens := []token{}
+
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+
+		// line comments
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#':
+			end := strings.IndexByte(query[i:], '\n')
+			if end == -1 {
+				i = len(query)
+			} else {
+				i += end
+			}
+			tokens = append(tokens, token{kind: tokenComment, text: query[start:i], pos: start})
+
+		// block comments
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			tok := token{kind: tokenComment, pos: start}
+			if end == -1 {
+				i = len(query)
+				tok.unterminated = true
+			} else {
+				i += end + 4
+			}
+			tok.text = query[start:i]
+			tokens = append(tokens, tok)
+
+		// strings and quoted identifiers
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			kind := tokenString
+			quote := c
+			if c == '`' || c == '[' {
+				kind = tokenIdent
+				if c == '[' {
+					quote = ']'
+				}
+			}
+
+			tok := token{kind: kind, pos: start, unterminated: true}
+			tok.backslash = kind == tokenString && dialect == MySQL
+
+			i++
+			for i < len(query) {
+				if query[i] == '\\' && tok.backslash {
+					// mysql style escape, used by `sqlEscapeQuote`
+					i += 2
+					continue
+				}
+
+				if query[i] == quote {
+					// a doubled quote is an escaped quote
+					if i+1 < len(query) && query[i+1] == quote && quote != ']' {
+						i += 2
+						continue
+					}
+
+					i++
+					tok.unterminated = false
+					break
+				}
+				i++
+			}
+			if i > len(query) {
+				i = len(query)
+			}
+			tok.text = query[start:i]
+			tokens = append(tokens, tok)
+
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			for i < len(query) && (isWordChar(query[i]) || query[i] == '.') {
+				// exponent sign: 1e+10
+				if (query[i] == 'e' || query[i] == 'E') && i+1 < len(query) && (query[i+1] == '+' || query[i+1] == '-') {
+					i++
+				}
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: query[start:i], pos: start})
+
+		case c == '?' || ((c == ':' || c == '@' || c == '$') && i+1 < len(query) && isWordChar(query[i+1])):
+			i++
+			for i < len(query) && isWordChar(query[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: query[start:i], pos: start})
+
+		case isWordChar(c) || c >= 0x80:
+			for i < len(query) && (isWordChar(query[i]) || query[i] == '$' || query[i] >= 0x80) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: query[start:i], pos: start})
+
+		case c == '(' || c == ')' || c == ',' || c == '.' || c == ';':
+			i++
+			tokens = append(tokens, token{kind: tokenPunct, text: query[start:i], pos: start})
+
+		default:
+			i++
+			for _, op := range []string{"<=>", "<>", "!=", "<=", ">=", "==", "||", "&&", "<<", ">>", "->>", "->"} {
+				if strings.HasPrefix(query[start:], op) {
+					i = start + len(op)
+					break
+				}
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: query[start:i], pos: start})
+		}
+	}
+
+	return tokens
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
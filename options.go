@@ -0,0 +1,190 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Option configures a database opened with `Open`
+type Option func(opts *options)
+
+// options are the settings of a database, before it is opened
+type options struct {
+	readOnly bool
+
+	// sqlite are the uri params of an sqlite database (see `WithSQLiteOptions`)
+	sqlite SQLiteOptions
+
+	// pool settings (-1 keeps the default)
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+
+	pingTimeout time.Duration
+	pingRetries int
+	pingBackoff time.Duration
+	lazy        bool
+
+	retry *RetryPolicy
+
+	// pragmas are set on every new sqlite connection (see `JournalMode`)
+	pragmas map[string]string
+
+	// readers is the size of the sqlite reader pool (see `ReaderPool`)
+	readers int
+
+	// err is an invalid option, returned by `Open`
+	err error
+}
+
+// newOptions applies a list of options to the default settings
+func newOptions(opts []Option) *options {
+	o := &options{
+		maxOpen:     -1,
+		maxIdle:     -1,
+		maxLifetime: -1,
+		maxIdleTime: -1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// pool sets the connection pool settings of a database
+//
+// @file: true for sqlite files, which default to a single connection
+func (o *options) pool(db *sql.DB, file bool) {
+	maxOpen, maxIdle, maxLifetime := 10, 10, time.Minute*3
+	if file {
+		maxOpen, maxIdle, maxLifetime = 1, -1, -1
+	}
+
+	if o.maxOpen >= 0 {
+		maxOpen = o.maxOpen
+	}
+	if o.maxIdle >= 0 {
+		maxIdle = o.maxIdle
+	}
+	if o.maxLifetime >= 0 {
+		maxLifetime = o.maxLifetime
+	}
+
+	db.SetMaxOpenConns(maxOpen)
+	if maxIdle >= 0 {
+		db.SetMaxIdleConns(maxIdle)
+	}
+	if maxLifetime >= 0 {
+		db.SetConnMaxLifetime(maxLifetime)
+	}
+	if o.maxIdleTime >= 0 {
+		db.SetConnMaxIdleTime(o.maxIdleTime)
+	}
+}
+
+// ping checks the connection to a database, and retries with backoff if it fails
+func (o *options) ping(db *sql.DB) error {
+	backoff := o.pingBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if o.pingTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, o.pingTimeout)
+		}
+
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil || attempt >= o.pingRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// ReadOnly opens the database in read only mode
+//
+// sqlite files are opened with `mode=ro`, so the driver will also deny writes.
+// For other drivers, use a database user without write permissions as well.
+//
+// See `db.ReadOnly` for the queries a read only database will deny.
+func ReadOnly() Option {
+	return func(opts *options) {
+		opts.readOnly = true
+	}
+}
+
+// MaxOpenConns sets the maximum number of open connections to the database
+//
+// default: 10 (1 for sqlite files)
+//
+// If n <= 0, there is no limit.
+func MaxOpenConns(n int) Option {
+	return func(opts *options) {
+		opts.maxOpen = max(n, 0)
+	}
+}
+
+// MaxIdleConns sets the maximum number of idle connections in the pool
+//
+// default: 10 (2 for sqlite files, the `database/sql` default)
+//
+// If n <= 0, no idle connections are kept.
+func MaxIdleConns(n int) Option {
+	return func(opts *options) {
+		opts.maxIdle = max(n, 0)
+	}
+}
+
+// ConnMaxLifetime sets the maximum amount of time a connection may be reused
+//
+// default: 3 minutes (no limit for sqlite files)
+//
+// If d <= 0, connections are reused forever.
+func ConnMaxLifetime(d time.Duration) Option {
+	return func(opts *options) {
+		opts.maxLifetime = max(d, 0)
+	}
+}
+
+// ConnMaxIdleTime sets the maximum amount of time a connection may be idle, before it is closed
+//
+// If d <= 0, connections are not closed for being idle (default).
+func ConnMaxIdleTime(d time.Duration) Option {
+	return func(opts *options) {
+		opts.maxIdleTime = max(d, 0)
+	}
+}
+
+// PingTimeout sets how long `Open` will wait for the database to respond to a ping
+//
+// By default, there is no timeout.
+func PingTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		opts.pingTimeout = d
+	}
+}
+
+// PingRetry will ping the database again if it fails to respond, while it is starting up
+//
+// @retries: the number of pings to try after the first one fails
+//
+// @backoff: the time to wait before the first retry, which doubles after every retry
+func PingRetry(retries int, backoff time.Duration) Option {
+	return func(opts *options) {
+		opts.pingRetries = retries
+		opts.pingBackoff = backoff
+	}
+}
+
+// LazyConnect skips the ping when the database is opened
+//
+// The first connection will be made by the first query instead,
+// which will return the connection error, if the database is down.
+func LazyConnect() Option {
+	return func(opts *options) {
+		opts.lazy = true
+	}
+}
@@ -0,0 +1,229 @@
+package gosql
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tkdeng/goregex"
+	"github.com/tkdeng/goutil"
+)
+
+// SafetyPolicy is the list of safety checks used by a database
+//
+// The built in safety checks always run first, followed by the custom checks
+// of the policy, in the order they were added.
+//
+// A SafetyPolicy is safe for concurrent use.
+type SafetyPolicy struct {
+	mu      sync.RWMutex
+	rules   []customRule
+	inherit bool
+
+	// allowed is the allowlist added with `AllowTable`
+	allowed *tableList
+
+	// schemas are the table lists of databases that enforce an allowlist
+	schemas []*tableList
+}
+
+// defaultSafetyPolicy holds the checks added with `AddSafetyCheck` and `AddSafetyCheckRE`
+var defaultSafetyPolicy = &SafetyPolicy{}
+
+// NewSafetyPolicy creates a new safety policy
+//
+// @inherit: if true, the custom checks of the default policy will also run,
+// including checks added to it later with `AddSafetyCheck`.
+// Set it to false to test a policy in isolation.
+func NewSafetyPolicy(inherit bool) *SafetyPolicy {
+	return &SafetyPolicy{
+		inherit: inherit,
+	}
+}
+
+// DefaultSafetyPolicy returns the package default policy
+//
+// `SafeQuery`, `CheckQuery`, `AddSafetyCheck` and `AddSafetyCheckRE` use this policy,
+// and every database inherits it, unless it was given another policy.
+func DefaultSafetyPolicy() *SafetyPolicy {
+	return defaultSafetyPolicy
+}
+
+// AddCheck adds a custom safety check to the policy
+//
+// @name: the rule name reported by `UnsafeQueryError`
+//
+// @message: a readable description of the rule
+//
+// return false, if you think the query looks unsafe.
+// return true, to continue down the safety check list.
+func (policy *SafetyPolicy) AddCheck(name string, message string, cb func(query string) bool) {
+	policy.add(customRule{name: name, message: message, cb: cb})
+}
+
+// AddCheckRE adds a custom regex safety check to the policy
+//
+// If the query matches this regex, the query will be seen as unsafe.
+//
+// @where: if true, will only check after the WHERE keyword
+func (policy *SafetyPolicy) AddCheckRE(name string, message string, re string, where ...bool) {
+	policy.add(customRule{
+		name:    name,
+		message: message,
+		re:      regex.Comp(re),
+		where:   len(where) != 0 && where[0],
+	})
+}
+
+func (policy *SafetyPolicy) add(rule customRule) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+
+	// getRules hands out the slice without the lock, so it is replaced instead of appended to
+	policy.rules = slices.Concat(policy.rules, []customRule{rule})
+}
+
+// getRules returns the custom checks of the policy, including inherited checks
+func (policy *SafetyPolicy) getRules() []customRule {
+	policy.mu.RLock()
+	rules, inherit := policy.rules, policy.inherit
+	policy.mu.RUnlock()
+
+	if inherit && policy != defaultSafetyPolicy {
+		// the default rules may have spare capacity, which other policies would write to
+		return slices.Concat(defaultSafetyPolicy.getRules(), rules)
+	}
+	return rules
+}
+
+// Clone returns a copy of the policy, which can be changed without affecting the original
+func (policy *SafetyPolicy) Clone() *SafetyPolicy {
+	policy.mu.RLock()
+	defer policy.mu.RUnlock()
+
+	clone := &SafetyPolicy{
+		rules:   append([]customRule{}, policy.rules...),
+		inherit: policy.inherit,
+		schemas: append([]*tableList{}, policy.schemas...),
+	}
+	if policy.allowed != nil {
+		clone.allowed = policy.allowed.clone()
+	}
+	return clone
+}
+
+// Safe returns true if a query passes the safety checks of the policy
+//
+// Strings are read with the sqlite rules, where a backslash does not escape a quote.
+// A database checks its statements with the rules of its own dialect.
+func (policy *SafetyPolicy) Safe(query string) bool {
+	_, err := policy.analyze(query, SQLite, nil)
+	return err == nil
+}
+
+// Check checks a query with the policy, and returns the reason it looks unsafe
+//
+// If the query looks safe, this method will return nil.
+// Otherwise, it will return an `*UnsafeQueryError` with the rule that failed.
+//
+// Strings are read with the sqlite rules (see `Safe`).
+func (policy *SafetyPolicy) Check(query string) error {
+	if _, err := policy.analyze(query, SQLite, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// analyze returns the first safety rule a query failed, or nil if the query looks safe
+//
+// @dialect: the dialect used to read strings (see `tokenize`)
+//
+// @skip: rule names to ignore
+//
+// @skipped: returns the names of ignored rules, that the query failed
+func (policy *SafetyPolicy) analyze(query string, dialect Dialect, skip []string) (skipped []string, err *UnsafeQueryError) {
+	fail := func(e *UnsafeQueryError) bool {
+		if goutil.Contains(skip, e.Rule) {
+			skipped = append(skipped, e.Rule)
+			return false
+		}
+		err = e
+		return true
+	}
+
+	if strings.TrimSpace(query) == "" {
+		if fail(&UnsafeQueryError{Rule: "empty", Message: "query is empty"}) {
+			return skipped, err
+		}
+		return skipped, nil
+	}
+
+	tokens := tokenize(query, dialect)
+
+	for _, rule := range safetyRules {
+		if e := rule(query, tokens); e != nil && fail(e) {
+			return skipped, err
+		}
+	}
+
+	for _, rule := range policy.getRules() {
+		if e := rule.check(query, tokens); e != nil && fail(e) {
+			return skipped, err
+		}
+	}
+
+	if lists := policy.allowlist(); len(lists) != 0 {
+		if e := checkAllowlist(query, tokens, lists, dialect); e != nil && fail(e) {
+			return skipped, err
+		}
+	}
+
+	return skipped, nil
+}
+
+// AddSafetyCheck adds another safety check to the SafeQuery method
+//
+// The check is added to the default safety policy, which is inherited by every
+// database that was not given its own policy (see `db.WithSafetyPolicy`).
+//
+// @name: the rule name reported by `UnsafeQueryError`
+//
+// @message: a readable description of the rule
+//
+// return false, if you think the query looks unsafe.
+// return true, to continue down the safety check list.
+func AddSafetyCheck(name string, message string, cb func(query string) bool) {
+	defaultSafetyPolicy.AddCheck(name, message, cb)
+}
+
+// AddSafetyCheckRE adds another safety check to the SafeQuery method
+//
+// The `RE` stands for RegExp, so you can simply pass a regex string,
+// which will check for a match, instead of a full callback method.
+//
+// If the query matches this regex, the query will be seen as unsafe.
+//
+// The check is added to the default safety policy, which is inherited by every
+// database that was not given its own policy (see `db.WithSafetyPolicy`).
+//
+// @name: the rule name reported by `UnsafeQueryError`
+//
+// @message: a readable description of the rule
+//
+// @where: if true, will only check after the WHERE keyword
+func AddSafetyCheckRE(name string, message string, re string, where ...bool) {
+	defaultSafetyPolicy.AddCheckRE(name, message, re, where...)
+}
+
+// SafetyPolicy returns the safety policy of the database
+//
+// Checks added to this policy only apply to this database (and its copies).
+func (db *DB) SafetyPolicy() *SafetyPolicy {
+	return db.policy
+}
+
+// WithSafetyPolicy returns a new database instance, which uses another safety policy
+func (db DB) WithSafetyPolicy(policy *SafetyPolicy) *DB {
+	db.policy = policy
+	return &db
+}
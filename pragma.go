@@ -0,0 +1,173 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkdeng/goutil"
+)
+
+// pragmaOrder is the order sqlite pragmas are set on a new connection
+//
+// busy_timeout is set first, so changing the journal mode will wait for other connections.
+var pragmaOrder = []string{"busy_timeout", "journal_mode", "synchronous", "foreign_keys", "cache_size", "mmap_size", "temp_store"}
+
+// setPragma adds a sqlite pragma to the options, if the value is one of the allowed values
+func (o *options) setPragma(name string, value string, allowed ...string) {
+	if len(allowed) != 0 {
+		value = strings.ToUpper(value)
+		if !goutil.Contains(allowed, value) {
+			o.err = errors.New("invalid " + name + ": " + value)
+			return
+		}
+	}
+
+	if o.pragmas == nil {
+		o.pragmas = map[string]string{}
+	}
+	o.pragmas[name] = value
+}
+
+// JournalMode sets the sqlite journal mode: "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL" or "OFF"
+//
+// In "WAL" mode, readers do not block the writer, so a file database can use more
+// than one connection (see `MaxOpenConns`).
+func JournalMode(mode string) Option {
+	return func(opts *options) {
+		opts.setPragma("journal_mode", mode, "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF")
+	}
+}
+
+// Synchronous sets how often sqlite waits for writes to reach the disk: "OFF", "NORMAL", "FULL" or "EXTRA"
+//
+// "NORMAL" is safe with the "WAL" journal mode, and much faster than "FULL".
+func Synchronous(mode string) Option {
+	return func(opts *options) {
+		opts.setPragma("synchronous", mode, "OFF", "NORMAL", "FULL", "EXTRA")
+	}
+}
+
+// BusyTimeout sets how long sqlite waits for a locked database, before it returns a busy error
+func BusyTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		opts.setPragma("busy_timeout", strconv.FormatInt(max(d.Milliseconds(), 0), 10))
+	}
+}
+
+// ForeignKeys enables or disables the foreign key constraints of sqlite
+func ForeignKeys(enabled bool) Option {
+	return func(opts *options) {
+		if enabled {
+			opts.setPragma("foreign_keys", "ON")
+		} else {
+			opts.setPragma("foreign_keys", "OFF")
+		}
+	}
+}
+
+// CacheSize sets the sqlite page cache size of each connection
+//
+// @n: a number of pages, or a negative number of kibibytes (like -2000 for about 2MB)
+func CacheSize(n int) Option {
+	return func(opts *options) {
+		opts.setPragma("cache_size", strconv.Itoa(n))
+	}
+}
+
+// MmapSize sets the maximum number of bytes sqlite will read with memory mapped io (0 disables it)
+func MmapSize(n int64) Option {
+	return func(opts *options) {
+		opts.setPragma("mmap_size", strconv.FormatInt(max(n, 0), 10))
+	}
+}
+
+// TempStore sets where sqlite keeps temporary tables and indexes: "DEFAULT", "FILE" or "MEMORY"
+func TempStore(mode string) Option {
+	return func(opts *options) {
+		opts.setPragma("temp_store", mode, "DEFAULT", "FILE", "MEMORY")
+	}
+}
+
+// Pragma returns the current value of a sqlite pragma, like "journal_mode" or "busy_timeout"
+//
+// The value is read from a single connection of the pool.
+func (db *DB) Pragma(name string) (string, error) {
+	name = toAlphaNumeric(name)
+
+	// pragmas are read with the raw database, since they are not part of the query builder
+	var value sql.NullString
+	err := db.SQL.QueryRow(`PRAGMA ` + name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("unknown pragma: " + name)
+	}
+	return value.String, err
+}
+
+// pragmaConnector opens sqlite connections, and sets the pragmas of the options on every new connection
+type pragmaConnector struct {
+	driver  driver.Driver
+	dsn     string
+	pragmas map[string]string
+}
+
+// newPragmaConnector returns a connector for a registered driver
+func newPragmaConnector(driverName string, dsn string, pragmas map[string]string) (*pragmaConnector, error) {
+	// opening a database does not connect, so this only looks up the driver
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	db.Close()
+
+	return &pragmaConnector{driver: drv, dsn: dsn, pragmas: pragmas}, nil
+}
+
+func (c *pragmaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range pragmaOrder {
+		value, ok := c.pragmas[name]
+		if !ok {
+			continue
+		}
+
+		if err := execConn(ctx, conn, `PRAGMA `+name+` = `+value); err != nil {
+			conn.Close()
+			return nil, errors.New("failed to set pragma " + name + ": " + err.Error())
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *pragmaConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// execConn runs a statement without args on a driver connection
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	st, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(nil)
+	return err
+}
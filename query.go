@@ -1,16 +1,78 @@
 package gosql
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 )
 
 type Query struct {
 	db    *DB
+	tx    *Tx
 	table string
 
 	where      string
 	whereValue []any
 	order      string
+
+	unsafe  *unsafeOverride
+	primary bool
+
+	// err is returned by every statement of the query, if `db.Table` failed to create the table
+	err error
+
+	// conds and orders describe the where and order queries as structured data (see `Statement`)
+	conds  []Condition
+	orders []Order
+}
+
+// unsafeOverride is the reason and list of safety rules skipped with `AllowUnsafe`
+type unsafeOverride struct {
+	reason string
+	rules  []string
+
+	// all is true if every rule is skipped with `AllowAllUnsafe`
+	all bool
+
+	// err is returned by every statement of the query, if the override is invalid
+	err error
+}
+
+// newUnsafeOverride returns the override of a query, or an error override if the reason is missing
+func newUnsafeOverride(reason string, rules []string, all bool) *unsafeOverride {
+	if strings.TrimSpace(reason) == "" {
+		return &unsafeOverride{err: errors.New("a reason is required to skip safety checks")}
+	} else if !all && len(rules) == 0 {
+		return &unsafeOverride{err: errors.New("no safety rules were named to skip (see AllowAllUnsafe)")}
+	}
+
+	return &unsafeOverride{reason: reason, rules: rules, all: all}
+}
+
+// AllowUnsafe will skip some of the sql safety checks, for the statements of this query only
+//
+// @reason: is required, and will be passed to hooks through `QueryInfo.UnsafeReason`,
+// so every bypass can be audited.
+//
+// @rules: the names of the safety rules to skip (see `UnsafeQueryError.Rule`).
+// At least one rule is required (see `AllowAllUnsafe` to skip every rule).
+//
+// If the reason or rules are missing, every statement of the query will return an error.
+//
+// Unlike `db.Unsafe`, this method does not affect any other query.
+func (query Query) AllowUnsafe(reason string, rules ...string) *Query {
+	query.unsafe = newUnsafeOverride(reason, rules, false)
+	return &query
+}
+
+// AllowAllUnsafe will skip every sql safety check, for the statements of this query only
+// (Not Recommended)
+//
+// @reason: is required, and will be passed to hooks through `QueryInfo.UnsafeReason`.
+// If the reason is missing, every statement of the query will return an error.
+func (query Query) AllowAllUnsafe(reason string) *Query {
+	query.unsafe = newUnsafeOverride(reason, nil, true)
+	return &query
 }
 
 // OrderBy will set ORDER BY key ASC|DESC
@@ -29,12 +91,28 @@ func (query Query) OrderBy(key string, desc ...bool) *Query {
 		query.order += ` ASC`
 	}
 
+	query.orders = append(query.orders[:len(query.orders):len(query.orders)], Order{
+		Key:  toAlphaNumeric(key),
+		Desc: len(desc) != 0 && desc[0],
+	})
+
 	return &query
 }
 
 type whereQuery struct {
 	query Query
 	where string
+	cond  Condition
+}
+
+// record adds the condition to the structured where query
+func (query *whereQuery) record(op string, values ...any) {
+	cond := query.cond
+	cond.Op = op
+	cond.Values = values
+
+	conds := query.query.conds
+	query.query.conds = append(conds[:len(conds):len(conds)], cond)
 }
 
 // Where will select WHERE key
@@ -42,8 +120,10 @@ type whereQuery struct {
 // set @truthy to false, to select WHERE NOT key
 func (query Query) Where(key string, truthy ...bool) *whereQuery {
 	q := `WHERE `
+	join := ``
 	if query.where != `` {
 		q = ` AND `
+		join = `AND`
 	}
 
 	if len(truthy) != 0 && !truthy[0] {
@@ -54,6 +134,11 @@ func (query Query) Where(key string, truthy ...bool) *whereQuery {
 	return &whereQuery{
 		query: query,
 		where: q,
+		cond: Condition{
+			Join: join,
+			Key:  toAlphaNumeric(key),
+			Not:  len(truthy) != 0 && !truthy[0],
+		},
 	}
 }
 
@@ -62,8 +147,10 @@ func (query Query) Where(key string, truthy ...bool) *whereQuery {
 // set @truthy to false, to select where ... AND NOT key
 func (query Query) And(key string, truthy ...bool) *whereQuery {
 	q := ` AND `
+	join := `AND`
 	if query.where == `` {
 		q = `WHERE `
+		join = ``
 	}
 
 	if len(truthy) != 0 && !truthy[0] {
@@ -74,6 +161,11 @@ func (query Query) And(key string, truthy ...bool) *whereQuery {
 	return &whereQuery{
 		query: query,
 		where: q,
+		cond: Condition{
+			Join: join,
+			Key:  toAlphaNumeric(key),
+			Not:  len(truthy) != 0 && !truthy[0],
+		},
 	}
 }
 
@@ -82,8 +174,10 @@ func (query Query) And(key string, truthy ...bool) *whereQuery {
 // set @truthy to false, to select where ... OR NOT key
 func (query Query) Or(key string, truthy ...bool) *whereQuery {
 	q := ` OR `
+	join := `OR`
 	if query.where == `` {
 		q = `WHERE `
+		join = ``
 	}
 
 	if len(truthy) != 0 && !truthy[0] {
@@ -94,6 +188,11 @@ func (query Query) Or(key string, truthy ...bool) *whereQuery {
 	return &whereQuery{
 		query: query,
 		where: q,
+		cond: Condition{
+			Join: join,
+			Key:  toAlphaNumeric(key),
+			Not:  len(truthy) != 0 && !truthy[0],
+		},
 	}
 }
 
@@ -101,6 +200,7 @@ func (query Query) Or(key string, truthy ...bool) *whereQuery {
 func (query whereQuery) Equal(value any) *Query {
 	query.query.where += query.where + ` = ?`
 	query.query.whereValue = append(query.query.whereValue, value)
+	query.record(`=`, value)
 	return &query.query
 }
 
@@ -108,6 +208,7 @@ func (query whereQuery) Equal(value any) *Query {
 func (query whereQuery) NotEqual(value any) *Query {
 	query.query.where += query.where + ` <> ?`
 	query.query.whereValue = append(query.query.whereValue, value)
+	query.record(`<>`, value)
 	return &query.query
 }
 
@@ -115,6 +216,7 @@ func (query whereQuery) NotEqual(value any) *Query {
 func (query whereQuery) Like(value any) *Query {
 	query.query.where += query.where + ` LIKE ?`
 	query.query.whereValue = append(query.query.whereValue, value)
+	query.record(`LIKE`, value)
 	return &query.query
 }
 
@@ -133,6 +235,7 @@ func (query whereQuery) In(values ...any) *Query {
 		}
 	}
 	query.query.where += `)`
+	query.record(`IN`, values...)
 
 	return &query.query
 }
@@ -140,41 +243,48 @@ func (query whereQuery) In(values ...any) *Query {
 // Greater Than `>`
 func (query whereQuery) GreaterThan(value int) *Query {
 	query.query.where += query.where + ` > ` + strconv.Itoa(value)
+	query.record(`>`, value)
 	return &query.query
 }
 
 // Less Than `<`
 func (query whereQuery) LessThan(value int) *Query {
 	query.query.where += query.where + ` < ` + strconv.Itoa(value)
+	query.record(`<`, value)
 	return &query.query
 }
 
 // Greater Than or Equal `>=`
 func (query whereQuery) GreaterEqual(value int) *Query {
 	query.query.where += query.where + ` >= ` + strconv.Itoa(value)
+	query.record(`>=`, value)
 	return &query.query
 }
 
 // Less Than or Equal `<=`
 func (query whereQuery) LessEqual(value int) *Query {
 	query.query.where += query.where + ` <= ` + strconv.Itoa(value)
+	query.record(`<=`, value)
 	return &query.query
 }
 
 // Between
 func (query whereQuery) Between(value1 int, value2 int) *Query {
 	query.query.where += query.where + ` BETWEEN ` + strconv.Itoa(value1) + ` AND ` + strconv.Itoa(value2)
+	query.record(`BETWEEN`, value1, value2)
 	return &query.query
 }
 
 // IsNull
 func (query whereQuery) IsNull() *Query {
 	query.query.where += query.where + ` IS NULL `
+	query.record(`IS NULL`)
 	return &query.query
 }
 
 // IsNotNull
 func (query whereQuery) IsNotNull() *Query {
 	query.query.where += query.where + ` IS NOT NULL `
+	query.record(`IS NOT NULL`)
 	return &query.query
 }
@@ -0,0 +1,79 @@
+package gosql
+
+import (
+	"github.com/tkdeng/goutil"
+)
+
+// readKeywords are the statements a read only database will run
+var readKeywords = []string{"SELECT", "WITH", "EXPLAIN"}
+
+// writeKeywords are keywords that can change a database, inside a reading statement
+//
+// `WITH ... DELETE`, `SELECT ... INTO` and `EXPLAIN ANALYZE INSERT` start like a read.
+var writeKeywords = []string{
+	"ALTER", "ATTACH", "CREATE", "DELETE", "DETACH", "DROP", "GRANT", "INSERT", "INTO", "LOAD", "MERGE",
+	"PRAGMA", "REINDEX", "REPLACE", "REVOKE", "TRUNCATE", "UPDATE", "UPSERT", "VACUUM",
+}
+
+// ReadOnly returns a new database instance, which can only read from the database
+//
+// `Set`, `Delete`, `Drop`, table creation with `db.Table` and raw `db.Exec` will
+// return `Error_ReadOnly`, and raw `db.Query` will only run SELECT, WITH and EXPLAIN statements.
+//
+// The new instance shares its connection with the original database.
+// To also deny writes in the driver, open the database with the `ReadOnly` option.
+func (db DB) ReadOnly() *DB {
+	db.readOnly = true
+	return &db
+}
+
+// IsReadOnly returns true if the database is in read only mode
+func (db *DB) IsReadOnly() bool {
+	return db.readOnly
+}
+
+// checkReadOnly returns `Error_ReadOnly` if a statement may write to a read only database
+func (db *DB) checkReadOnly(info *QueryInfo) error {
+	if !db.readOnly {
+		return nil
+	}
+
+	switch info.Op {
+	case OpSelect:
+		return nil
+	case OpRaw:
+		if !info.write && readingStatement(info.SQL, db.dialect) {
+			return nil
+		}
+	}
+
+	return Error_ReadOnly
+}
+
+// readingStatement returns true if a raw query can only read from the database
+func readingStatement(query string, dialect Dialect) bool {
+	tokens := []token{}
+	for _, tok := range tokenize(query, dialect) {
+		if tok.kind != tokenComment {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	if len(tokens) == 0 || !goutil.Contains(readKeywords, tokens[0].keyword()) {
+		return false
+	}
+
+	for i, tok := range tokens {
+		if !goutil.Contains(writeKeywords, tok.keyword()) {
+			continue
+		}
+
+		// functions like `replace(name, 'a', 'b')`
+		if i+1 < len(tokens) && tokens[i+1].kind == tokenPunct && tokens[i+1].text == "(" {
+			continue
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,450 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Method is the query builder method of a `Statement`
+type Method string
+
+const (
+	MethodGet    Method = "get"
+	MethodHas    Method = "has"
+	MethodCount  Method = "count"
+	MethodSet    Method = "set"
+	MethodDelete Method = "delete"
+)
+
+// Statement is a query builder operation, described as data instead of sql
+//
+// A remote database (see `OpenRemote`) sends statements to a server, which runs them with `db.Run`.
+// The server builds the sql itself, so it can check every statement with its own safety policy.
+type Statement struct {
+	Method Method      `json:"method"`
+	Table  string      `json:"table"`
+	Where  []Condition `json:"where,omitempty"`
+	Order  []Order     `json:"order,omitempty"`
+
+	// Keys are the selected keys of Get, or the unique keys of Set
+	Keys []string `json:"keys,omitempty"`
+
+	// Values are the values of Has and Set
+	Values Values `json:"values,omitempty"`
+
+	// Force allows Delete without a where query
+	Force bool `json:"force,omitempty"`
+}
+
+// Condition is a single where condition of a statement
+type Condition struct {
+	// Join is "AND" or "OR" (empty for the first condition)
+	Join string `json:"join,omitempty"`
+
+	Key string `json:"key"`
+	Not bool   `json:"not,omitempty"`
+
+	// Op is one of: "=", "<>", "LIKE", "IN", ">", "<", ">=", "<=", "BETWEEN", "IS NULL", "IS NOT NULL"
+	Op     string `json:"op"`
+	Values []any  `json:"values,omitempty"`
+}
+
+// Order is a single ORDER BY key of a statement
+type Order struct {
+	Key  string `json:"key"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+// Remote runs the statements of a remote database
+//
+// The gosqlserver package has an http client and server.
+type Remote interface {
+	// Run runs a statement, and calls cb for every row it returns (see `db.Run`)
+	Run(ctx context.Context, stmt *Statement, cb func(row []any) bool) error
+}
+
+// errRemoteSQL is returned by the raw sql methods of a remote database
+var errRemoteSQL = errors.New("raw sql is not supported by a remote database")
+
+// OpenRemote opens a database, which sends the statements of the query builder to a remote server
+//
+// Only the query builder methods `Get`, `Count`, `Has`, `Set` and `Delete` are supported.
+// Raw sql (like `db.Query` or `db.Exec`), transactions and `Drop` will return an error.
+//
+// Hooks and safety checks of the local database also run before a statement is sent,
+// but the server will check it again with its own safety policy.
+//
+// @opts: optional settings, like `ReadOnly()`
+func OpenRemote(remote Remote, opts ...Option) (*DB, error) {
+	o := newOptions(opts)
+	o.lazy = true
+
+	db, err := newDB(sql.OpenDB(remoteConnector{}), SQLite, o, false)
+	if err != nil {
+		return nil, err
+	}
+
+	db.remote = remote
+	return db, nil
+}
+
+// remoteConnector is the sql connector of a remote database, which denies raw sql
+type remoteConnector struct{}
+
+func (remoteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return nil, errRemoteSQL
+}
+
+func (remoteConnector) Driver() driver.Driver {
+	return remoteDriver{}
+}
+
+type remoteDriver struct{}
+
+func (remoteDriver) Open(name string) (driver.Conn, error) {
+	return nil, errRemoteSQL
+}
+
+// Run runs a statement with the query builder, and calls cb for every row it returns
+//
+// This is how a server runs the statements of a remote database (see `OpenRemote`).
+// The statements pass through the safety checks and hooks of this database.
+//   - Get returns the selected rows
+//   - Has returns a single row with a bool
+//   - Count returns a single row with the number of rows
+//   - Set and Delete return no rows
+//
+// Numbers decoded from json (as `json.Number`) are converted to int64 or float64.
+func (db *DB) Run(stmt *Statement, cb func(row []any) bool) error {
+	if db.remote != nil {
+		return db.remote.Run(context.Background(), stmt, cb)
+	}
+
+	query, err := db.statementQuery(stmt)
+	if err != nil {
+		return err
+	}
+
+	values := make(Values, len(stmt.Values))
+	for i, val := range stmt.Values {
+		values[i] = Value{Key: val.Key, Value: jsonValue(val.Value)}
+	}
+
+	switch stmt.Method {
+	case MethodGet:
+		var scanErr error
+		q, args := query.SelectSQL(stmt.Keys)
+		err := query.each(OpSelect, q, args, func(rows *sql.Rows) bool {
+			columns, err := rows.Columns()
+			if err != nil {
+				scanErr = err
+				return false
+			}
+
+			row := make([]any, len(columns))
+			dest := make([]any, len(columns))
+			for i := range row {
+				dest[i] = &row[i]
+			}
+
+			if scanErr = rows.Scan(dest...); scanErr != nil {
+				return false
+			}
+			return cb(row)
+		})
+		if err != nil {
+			return err
+		}
+		return scanErr
+
+	case MethodHas:
+		found := false
+		if len(values) != 0 {
+			q, args := query.HasSQL(values)
+			err := query.each(OpSelect, q, args, func(rows *sql.Rows) bool {
+				found = true
+				return false
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cb([]any{found})
+		return nil
+
+	case MethodCount:
+		count, err := query.Count()
+		if err != nil {
+			return err
+		}
+		cb([]any{count})
+		return nil
+
+	case MethodSet:
+		return query.SetValues(values, stmt.Keys...)
+
+	case MethodDelete:
+		return query.Delete(stmt.Force)
+	}
+
+	return errors.New("unknown statement method: " + string(stmt.Method))
+}
+
+// statementQuery builds the query of a statement
+func (db *DB) statementQuery(stmt *Statement) (*Query, error) {
+	if toAlphaNumeric(stmt.Table) == "" {
+		return nil, errors.New("statement has no table")
+	}
+
+	query := db.Table(stmt.Table)
+
+	for _, cond := range stmt.Where {
+		where := query.And(cond.Key, !cond.Not)
+		if cond.Join == "OR" {
+			where = query.Or(cond.Key, !cond.Not)
+		}
+
+		values := make([]any, len(cond.Values))
+		ints := make([]int, 0, len(cond.Values))
+		for i, val := range cond.Values {
+			values[i] = jsonValue(val)
+			if n, ok := intValue(values[i]); ok {
+				ints = append(ints, n)
+			}
+		}
+
+		invalid := errors.New("invalid values for condition: " + cond.Op)
+
+		switch cond.Op {
+		case "=", "<>", "LIKE":
+			if len(values) != 1 {
+				return nil, invalid
+			}
+
+			switch cond.Op {
+			case "=":
+				query = where.Equal(values[0])
+			case "<>":
+				query = where.NotEqual(values[0])
+			default:
+				query = where.Like(values[0])
+			}
+		case "IN":
+			if len(values) == 0 {
+				return nil, invalid
+			}
+			query = where.In(values...)
+		case ">", "<", ">=", "<=":
+			if len(values) != 1 || len(ints) != 1 {
+				return nil, invalid
+			}
+
+			switch cond.Op {
+			case ">":
+				query = where.GreaterThan(ints[0])
+			case "<":
+				query = where.LessThan(ints[0])
+			case ">=":
+				query = where.GreaterEqual(ints[0])
+			default:
+				query = where.LessEqual(ints[0])
+			}
+		case "BETWEEN":
+			if len(values) != 2 || len(ints) != 2 {
+				return nil, invalid
+			}
+			query = where.Between(ints[0], ints[1])
+		case "IS NULL":
+			query = where.IsNull()
+		case "IS NOT NULL":
+			query = where.IsNotNull()
+		default:
+			return nil, errors.New("unknown condition: " + cond.Op)
+		}
+	}
+
+	for _, order := range stmt.Order {
+		query = query.OrderBy(order.Key, order.Desc)
+	}
+
+	return query, nil
+}
+
+// runRemote sends a statement of the query to the remote database
+//
+// The statement passes through the read only and safety checks and hooks,
+// with the sql the query would run locally.
+func (query *Query) runRemote(op Op, q string, args []any, stmt *Statement, cb func(row []any) bool) error {
+	stmt.Table = query.table
+	stmt.Where = query.conds
+	stmt.Order = query.orders
+
+	info := query.info(op, q, args)
+	return query.db.run(context.Background(), info, func() (sql.Result, error) {
+		return nil, query.db.remote.Run(context.Background(), stmt, func(row []any) bool {
+			info.Rows++
+			if cb == nil {
+				return true
+			}
+			return cb(row)
+		})
+	})
+}
+
+// jsonValue converts a number decoded from json to an int64 or float64
+func jsonValue(val any) any {
+	if n, ok := val.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		f, _ := n.Float64()
+		return f
+	}
+	return val
+}
+
+// intValue returns the value of an integer
+func intValue(val any) (int, bool) {
+	switch val := jsonValue(val).(type) {
+	case int:
+		return val, true
+	case int64:
+		return int(val), true
+	case float64:
+		if val == float64(int(val)) {
+			return int(val), true
+		}
+	}
+	return 0, false
+}
+
+// scanRow copies the values of a remote row into dest, like `rows.Scan`
+func scanRow(row []any, dest []any) error {
+	if len(dest) != len(row) {
+		return errors.New("expected " + strconv.Itoa(len(row)) + " destination arguments in Scan, not " + strconv.Itoa(len(dest)))
+	}
+
+	for i := range dest {
+		if err := scanValue(dest[i], row[i]); err != nil {
+			return errors.New("converting column " + strconv.Itoa(i) + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// scanValue copies a json value into a pointer
+func scanValue(dest any, src any) error {
+	src = jsonValue(src)
+
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(src)
+	case *any:
+		*d = src
+		return nil
+	case *[]byte:
+		if src == nil {
+			*d = nil
+		} else {
+			*d = []byte(textValue(src))
+		}
+		return nil
+	case *time.Time:
+		if src == nil {
+			break
+		}
+
+		text := textValue(src)
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", time.DateOnly} {
+			if t, err := time.Parse(layout, text); err == nil {
+				*d = t
+				return nil
+			}
+		}
+		return errors.New("invalid time: " + strconv.Quote(text))
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return errors.New("destination is not a pointer")
+	}
+	dv = dv.Elem()
+
+	if dv.Kind() == reflect.Pointer {
+		if src == nil {
+			dv.SetZero()
+			return nil
+		}
+
+		val := reflect.New(dv.Type().Elem())
+		if err := scanValue(val.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(val)
+		return nil
+	}
+
+	if src == nil {
+		return errors.New("converting NULL to " + dv.Kind().String() + " is unsupported")
+	}
+
+	text := textValue(src)
+	switch dv.Kind() {
+	case reflect.String:
+		dv.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		dv.SetBool(b)
+	default:
+		return errors.New("unsupported scan type: " + dv.Type().String())
+	}
+	return nil
+}
+
+// textValue returns the text of a json value
+func textValue(val any) string {
+	switch val := val.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+
+	data, _ := json.Marshal(val)
+	return string(data)
+}
@@ -0,0 +1,204 @@
+package gosql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RetryPolicy runs a statement again, if it failed with a transient error
+//
+// Reads from the query builder (like `Get`, `Count` and `Has`) are retried if they failed
+// before any rows were read, and transactions from `db.Tx` are retried as a whole.
+// Writes outside of `db.Tx` are never retried, since they may not be idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a statement runs (default: 3)
+	MaxAttempts int
+
+	// Backoff is the time to wait before the first retry, which doubles after every retry (default: 10ms)
+	//
+	// A random jitter of up to half the backoff is taken off each wait.
+	Backoff time.Duration
+
+	// MaxBackoff is the maximum time to wait between retries (default: 1s)
+	MaxBackoff time.Duration
+
+	// Retryable returns true for transient errors (default: `IsRetryable`)
+	Retryable func(err error) bool
+}
+
+// Retry sets the retry policy of the database
+//
+// This lets sqlite files use more than one connection (see `MaxOpenConns`),
+// since a statement that finds the database busy will wait and run again.
+func Retry(policy RetryPolicy) Option {
+	return func(opts *options) {
+		opts.retry = &policy
+	}
+}
+
+// IsRetryable returns true for errors that may not happen again, if the statement runs again
+//
+// This includes a busy or locked sqlite database, mysql deadlocks (1213) and
+// lock wait timeouts (1205), and broken connections.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	return isLockError(err)
+}
+
+// isLockError returns true if a statement failed to get a lock, so it did not change anything
+//
+// Unlike a broken connection, a commit that failed with a lock error was not applied.
+func isLockError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	// sqlite errors are matched by message, so the sqlite driver is not required
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "SQLITE_BUSY")
+}
+
+// do runs fn, and runs it again while it fails with a retryable error
+func (policy *RetryPolicy) do(ctx context.Context, fn func() error) error {
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.wait(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// wait returns the jittered backoff before a retry
+func (policy *RetryPolicy) wait(attempt int) time.Duration {
+	backoff, maxBackoff := policy.Backoff, policy.MaxBackoff
+	if backoff <= 0 {
+		backoff = time.Millisecond * 10
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+
+	return backoff - rand.N(backoff/2+1)
+}
+
+// retry runs a read again if it failed with a transient error, before it read any rows
+//
+// Reads inside a transaction are not retried, since the transaction may have been aborted.
+func (query *Query) retry(info *QueryInfo, fn func() error) error {
+	if query.tx != nil || query.db.retry == nil {
+		return fn()
+	}
+
+	// once rows are passed to the callback, the read can not run again
+	var final error
+	err := query.db.retry.do(context.Background(), func() error {
+		err := fn()
+		if err != nil && info.Rows != 0 {
+			final = err
+			return nil
+		}
+		return err
+	})
+	if final != nil {
+		return final
+	}
+	return err
+}
+
+// Tx runs fn inside a transaction, and commits it if fn returns nil
+//
+// If fn returns an error, or panics, the transaction will be rolled back.
+//
+// With a retry policy (see `Retry`), the whole transaction will run again if it fails
+// with a retryable error, like a deadlock. fn should not have side effects outside of
+// the transaction, since it may run more than once.
+//
+// If the commit fails, the transaction only runs again for lock errors (mysql deadlocks
+// and lock wait timeouts, or a busy sqlite database). After a broken connection, the
+// commit may have been applied, so running the transaction again could apply it twice.
+func (db *DB) Tx(fn func(tx *Tx) error) error {
+	// a commit error that must not be retried
+	var commitErr error
+
+	run := func() error {
+		commitErr = nil
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+				panic(r)
+			}
+		}()
+
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil && !isLockError(err) {
+			commitErr = err
+			return nil
+		}
+		return err
+	}
+
+	var err error
+	if db.retry == nil {
+		err = run()
+	} else {
+		err = db.retry.do(context.Background(), run)
+	}
+
+	if commitErr != nil {
+		return commitErr
+	}
+	return err
+}
@@ -0,0 +1,273 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+)
+
+// RegisterSafeDriver registers a `database/sql` driver, which runs the safety checks and hooks
+// of gosql on every statement of another driver
+//
+// Code that uses `db.SQL`, or a third party library that opens its own `*sql.DB`,
+// can use the new driver name, and will not be able to bypass the safety checks.
+//
+//	gosql.RegisterSafeDriver("sqlite3-safe", "sqlite3", gosql.DefaultSafetyPolicy())
+//	db, err := sql.Open("sqlite3-safe", "file:data.db")
+//
+// A statement is checked when it is prepared, or when it runs without args.
+// Hooks are called every time a statement runs, with the `OpRaw` operation.
+//
+// Note: a database opened with `Open` will check the statements of the query builder twice,
+// and statements that skip the safety checks (like `Drop`) will be denied by the driver.
+//
+// @base: the name of a registered driver, like "sqlite3" or "mysql"
+//
+// @policy: the safety policy of the driver (default: `NewSafetyPolicy(true)`).
+// The policy is not copied, so rules added later will also be checked.
+func RegisterSafeDriver(name string, base string, policy *SafetyPolicy, hooks ...Hook) error {
+	if slices.Contains(sql.Drivers(), name) {
+		return errors.New("sql: driver " + name + " is already registered")
+	}
+
+	// opening a database does not connect, so this only looks up the driver
+	db, err := sql.Open(base, "")
+	if err != nil {
+		return err
+	}
+	drv := db.Driver()
+	db.Close()
+
+	if policy == nil {
+		policy = NewSafetyPolicy(true)
+	}
+
+	sql.Register(name, &safeDriver{
+		base:    drv,
+		policy:  policy,
+		hooks:   slices.Clone(hooks),
+		dialect: dialectOf(base),
+	})
+	return nil
+}
+
+// safeDriver wraps the connections of a driver with safety checks and hooks
+type safeDriver struct {
+	base   driver.Driver
+	policy *SafetyPolicy
+	hooks  []Hook
+
+	// dialect is the dialect of the base driver, used by the safety checks
+	dialect Dialect
+}
+
+func (d *safeDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &safeConn{base: conn, driver: d}, nil
+}
+
+// info describes a statement run by the driver
+func (d *safeDriver) info(query string, args []driver.NamedValue) *QueryInfo {
+	info := &QueryInfo{SQL: query, Op: OpRaw}
+	for _, arg := range args {
+		if arg.Name != "" {
+			info.Args = append(info.Args, sql.Named(arg.Name, arg.Value))
+		} else {
+			info.Args = append(info.Args, arg.Value)
+		}
+	}
+	return info
+}
+
+// check runs the safety checks on a statement, before it is prepared
+func (d *safeDriver) check(ctx context.Context, query string) error {
+	if _, err := d.policy.analyze(query, d.dialect, nil); err != nil {
+		return afterHooks(ctx, d.hooks, d.info(query, nil), nil, err, 0)
+	}
+	return nil
+}
+
+// safeConn is a connection of a safe driver
+//
+// The optional interfaces of the base connection are passed through,
+// with the defaults of `database/sql` if the base connection does not implement them.
+type safeConn struct {
+	base   driver.Conn
+	driver *safeDriver
+}
+
+func (c *safeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *safeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.driver.check(ctx, query); err != nil {
+		return nil, err
+	}
+
+	var st driver.Stmt
+	var err error
+	if prep, ok := c.base.(driver.ConnPrepareContext); ok {
+		st, err = prep.PrepareContext(ctx, query)
+	} else {
+		st, err = c.base.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &safeStmt{base: st, conn: c, query: query}, nil
+}
+
+func (c *safeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.base.(driver.ExecerContext)
+	if !ok || len(args) != 0 {
+		// statements with args are prepared, and run once the prepared statement is checked
+		return nil, driver.ErrSkip
+	}
+
+	var res driver.Result
+	err := runHooks(ctx, c.driver.policy, c.driver.dialect, c.driver.hooks, c.driver.info(query, args), func() (sql.Result, error) {
+		var err error
+		res, err = execer.ExecContext(ctx, query, args)
+		return res, err
+	})
+	return res, err
+}
+
+func (c *safeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.base.(driver.QueryerContext)
+	if !ok || len(args) != 0 {
+		return nil, driver.ErrSkip
+	}
+
+	var rows driver.Rows
+	err := runHooks(ctx, c.driver.policy, c.driver.dialect, c.driver.hooks, c.driver.info(query, args), func() (sql.Result, error) {
+		var err error
+		rows, err = queryer.QueryContext(ctx, query, args)
+		return nil, err
+	})
+	return rows, err
+}
+
+func (c *safeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *safeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if begin, ok := c.base.(driver.ConnBeginTx); ok {
+		return begin.BeginTx(ctx, opts)
+	}
+	return c.base.Begin()
+}
+
+func (c *safeConn) Close() error {
+	return c.base.Close()
+}
+
+func (c *safeConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.base.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *safeConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.base.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *safeConn) IsValid() bool {
+	if validator, ok := c.base.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *safeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.base.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// safeStmt is a prepared statement of a safe driver, which passed the safety checks
+type safeStmt struct {
+	base  driver.Stmt
+	conn  *safeConn
+	query string
+}
+
+func (st *safeStmt) Close() error {
+	return st.base.Close()
+}
+
+func (st *safeStmt) NumInput() int {
+	return st.base.NumInput()
+}
+
+func (st *safeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return st.ExecContext(context.Background(), namedValues(args))
+}
+
+func (st *safeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return st.QueryContext(context.Background(), namedValues(args))
+}
+
+func (st *safeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+	err := runHooks(ctx, nil, st.conn.driver.dialect, st.conn.driver.hooks, st.conn.driver.info(st.query, args), func() (sql.Result, error) {
+		var err error
+		if execer, ok := st.base.(driver.StmtExecContext); ok {
+			res, err = execer.ExecContext(ctx, args)
+		} else if values, e := driverValues(args); e != nil {
+			err = e
+		} else {
+			res, err = st.base.Exec(values)
+		}
+		return res, err
+	})
+	return res, err
+}
+
+func (st *safeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := runHooks(ctx, nil, st.conn.driver.dialect, st.conn.driver.hooks, st.conn.driver.info(st.query, args), func() (sql.Result, error) {
+		var err error
+		if queryer, ok := st.base.(driver.StmtQueryContext); ok {
+			rows, err = queryer.QueryContext(ctx, args)
+		} else if values, e := driverValues(args); e != nil {
+			err = e
+		} else {
+			rows, err = st.base.Query(values)
+		}
+		return nil, err
+	})
+	return rows, err
+}
+
+func (st *safeStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := st.base.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return st.conn.CheckNamedValue(nv)
+}
+
+// driverValues converts named values to positional args, for drivers without named args
+func driverValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
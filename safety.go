@@ -0,0 +1,460 @@
+package gosql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tkdeng/goregex"
+	"github.com/tkdeng/goutil"
+)
+
+// customRule is a safety check added to a SafetyPolicy
+type customRule struct {
+	name    string
+	message string
+
+	cb    func(query string) bool
+	re    *regex.Regexp
+	where bool
+}
+
+// UnsafeQueryError describes why a query failed the safety checks
+//
+// It matches `Error_UnsafeQuery` with `errors.Is`.
+type UnsafeQueryError struct {
+	// Rule is the name of the safety rule that failed
+	Rule string
+
+	// Message is a readable description of the rule
+	Message string
+
+	// Fragment is the part of the query that matched the rule
+	Fragment string
+
+	// Pos is the byte offset of the fragment in the query
+	Pos int
+}
+
+func (err *UnsafeQueryError) Error() string {
+	msg := Error_UnsafeQuery.Error() + ": " + err.Message + " (" + err.Rule + ")"
+	if err.Fragment != "" {
+		msg += " at " + strconv.Itoa(err.Pos) + ": " + strconv.Quote(err.Fragment)
+	}
+	return msg
+}
+
+// Is returns true for `Error_UnsafeQuery`
+func (err *UnsafeQueryError) Is(target error) bool {
+	return target == Error_UnsafeQuery
+}
+
+// safetyRule is a built in safety check, which runs on the tokens of a query
+type safetyRule func(query string, tokens []token) *UnsafeQueryError
+
+// safetyRules is the list of built in safety checks, in the order they run
+var safetyRules = []safetyRule{
+	checkUnterminated,
+	checkComments,
+	checkMultiStatement,
+	checkDDL,
+	checkTautology,
+	checkCredentialWildcard,
+}
+
+// SafeQuery checks a query for common safety errors
+//
+// Note: this safety check does Not guarantee safety, and should Not be relied on.
+//
+// This method is called by default, unless `db.SQL` is used to access raw sql from
+// the default `database/sql` module.
+//
+// The query is split into tokens first, so string literals, quoted identifiers
+// and comments are understood, and a value like `'dropped'` or `'a;b'` will
+// not be mistaken for sql.
+//
+// Strings are read with the sqlite rules, where a backslash does not escape a quote.
+//
+// Custom checks are read from the default safety policy (see `AddSafetyCheck`).
+func SafeQuery(query string) bool {
+	return defaultSafetyPolicy.Safe(query)
+}
+
+// CheckQuery checks a query for common safety errors, and returns the reason it looks unsafe
+//
+// If the query looks safe, this method will return nil.
+// Otherwise, it will return an `*UnsafeQueryError` with the rule that failed.
+//
+// Note: this safety check does Not guarantee safety, and should Not be relied on.
+func CheckQuery(query string) error {
+	return defaultSafetyPolicy.Check(query)
+}
+
+// unsafeTokens returns the error of a failed rule, for a range of tokens
+func unsafeTokens(rule string, message string, query string, tokens []token) *UnsafeQueryError {
+	first, last := tokens[0], tokens[len(tokens)-1]
+	return &UnsafeQueryError{
+		Rule:     rule,
+		Message:  message,
+		Fragment: query[first.pos : last.pos+len(last.text)],
+		Pos:      first.pos,
+	}
+}
+
+// whereClause returns the text of the query after the first WHERE keyword
+func whereClause(query string, tokens []token) string {
+	for _, tok := range tokens {
+		if tok.keyword() == "WHERE" {
+			return query[tok.pos:]
+		}
+	}
+	return ""
+}
+
+// checkUnterminated denies strings, identifiers and comments that are never closed
+//
+// an unterminated quote is a common sign of an escaped input.
+func checkUnterminated(query string, tokens []token) *UnsafeQueryError {
+	for _, tok := range tokens {
+		if tok.unterminated {
+			return unsafeTokens("unterminated", "unterminated quote or comment", query, []token{tok})
+		}
+	}
+	return nil
+}
+
+// checkComments denies comments outside of string literals
+//
+// common sql injection: `admin'--` or `admin'/*` to ignore the rest of a query.
+func checkComments(query string, tokens []token) *UnsafeQueryError {
+	for _, tok := range tokens {
+		if tok.kind == tokenComment {
+			return unsafeTokens("comment", "comments are not allowed", query, []token{tok})
+		}
+	}
+	return nil
+}
+
+// checkMultiStatement denies more than one statement in a query
+//
+// a single trailing `;` is allowed.
+func checkMultiStatement(query string, tokens []token) *UnsafeQueryError {
+	for i, tok := range tokens {
+		if tok.kind == tokenPunct && tok.text == ";" {
+			for _, next := range tokens[i+1:] {
+				if next.kind != tokenComment && next.text != ";" {
+					return unsafeTokens("multi-statement", "multiple statements are not allowed", query, []token{tok, next})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var ddlKeywords = []string{"DROP", "TRUNCATE", "ALTER", "GRANT", "REVOKE"}
+var ddlObjects = []string{"TABLE", "DATABASE", "SCHEMA", "INDEX", "VIEW", "TRIGGER", "USER", "COLUMN", "PROCEDURE", "FUNCTION", "TEMPORARY"}
+
+// checkDDL denies statements that can destroy tables or permissions, like `DROP TABLE`
+//
+// A keyword like `drop` is only denied when it starts a statement, or is followed
+// by an object like `TABLE`, so it can still be used as a column name.
+func checkDDL(query string, tokens []token) *UnsafeQueryError {
+	start := true
+	for i, tok := range tokens {
+		if tok.kind == tokenComment {
+			continue
+		}
+
+		if kw := tok.keyword(); kw != "" && goutil.Contains(ddlKeywords, kw) {
+			if start || (i+1 < len(tokens) && goutil.Contains(ddlObjects, tokens[i+1].keyword())) {
+				end := i
+				if end+1 < len(tokens) {
+					end++
+				}
+				return unsafeTokens("ddl", kw+" statements are not allowed", query, tokens[i:end+1])
+			}
+		}
+
+		start = tok.kind == tokenPunct && tok.text == ";"
+	}
+	return nil
+}
+
+var comparisonOps = []string{"=", "==", "<>", "!=", "<", ">", "<=", ">=", "<=>", "LIKE"}
+
+// checkTautology denies conditions that are always true
+//
+// common sql injection: `OR 1=1`, `OR 'a'='a'`, `OR 2>1` or `OR id=id`.
+func checkTautology(query string, tokens []token) *UnsafeQueryError {
+	inCond := false
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].keyword() {
+		case "WHERE", "ON", "HAVING", "WHEN":
+			inCond = true
+			continue
+		case "SELECT", "FROM", "GROUP", "ORDER", "LIMIT", "UNION", "SET", "VALUES", "RETURNING":
+			inCond = false
+			continue
+		case "OR":
+			// bare truthy value: `OR 1` or `OR TRUE`
+			if inCond && i+1 < len(tokens) {
+				if end, ok := operand(tokens, i+1); ok && end == i+2 && endsExpression(tokens, end) {
+					if num, isNum := literalNumber(tokens[i+1]); isNum && num != 0 {
+						return unsafeTokens("tautology", "condition is always true", query, tokens[i:end])
+					}
+				}
+			}
+			continue
+		}
+
+		if !inCond || (i != 0 && !startsExpression(tokens[i-1])) {
+			continue
+		}
+
+		aEnd, ok := operand(tokens, i)
+		if !ok || aEnd >= len(tokens) {
+			continue
+		}
+
+		op := tokens[aEnd]
+		opText := op.text
+		if kw := op.keyword(); kw != "" {
+			opText = kw
+		}
+		if (op.kind != tokenOperator && op.keyword() != "LIKE") || !goutil.Contains(comparisonOps, opText) {
+			continue
+		}
+
+		bEnd, ok := operand(tokens, aEnd+1)
+		if !ok || !endsExpression(tokens, bEnd) {
+			continue
+		}
+
+		a, b := tokens[i:aEnd], tokens[aEnd+1:bEnd]
+		if isLiteral(a) && isLiteral(b) {
+			if compareLiterals(a[0], b[0], opText) {
+				return unsafeTokens("tautology", "condition is always true", query, tokens[i:bEnd])
+			}
+		} else if !isLiteral(a) && !isLiteral(b) && sameOperand(a, b) && opText != "<>" && opText != "!=" && opText != "<" && opText != ">" {
+			return unsafeTokens("tautology", "condition compares a value to itself", query, tokens[i:bEnd])
+		}
+	}
+	return nil
+}
+
+// checkCredentialWildcard denies a credential compared to a wildcard
+//
+// common sql injection: username = '*' AND password = '*'
+func checkCredentialWildcard(query string, tokens []token) *UnsafeQueryError {
+	inWhere := false
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].keyword() == "WHERE" {
+			inWhere = true
+		}
+		if !inWhere {
+			continue
+		}
+
+		key := tokens[i]
+		if (key.kind == tokenWord || key.kind == tokenIdent || key.kind == tokenString) && tokens[i+1].text == "=" && tokens[i+2].value() == "*" {
+			if regex.Comp(`(?i)^(user(name|id|)|pass(word|)|u*id)$`).Match([]byte(key.value())) {
+				return unsafeTokens("credential-wildcard", "credential compared to a wildcard", query, tokens[i:i+3])
+			}
+		}
+	}
+	return nil
+}
+
+// operand returns the end of a simple operand starting at tokens[i]
+//
+// an operand is a literal, a placeholder or a (qualified) column name.
+func operand(tokens []token, i int) (end int, ok bool) {
+	if i >= len(tokens) {
+		return i, false
+	}
+
+	switch tok := tokens[i]; tok.kind {
+	case tokenString, tokenNumber, tokenParam:
+		return i + 1, true
+	case tokenWord:
+		if kw := tok.keyword(); kw == "TRUE" || kw == "FALSE" {
+			return i + 1, true
+		} else if goutil.Contains(sqlKeywords, kw) {
+			return i, false
+		}
+	case tokenIdent:
+	default:
+		return i, false
+	}
+
+	// column name, with optional table prefix: table.column
+	end = i + 1
+	for end+1 < len(tokens) && tokens[end].text == "." && (tokens[end+1].kind == tokenWord || tokens[end+1].kind == tokenIdent) {
+		end += 2
+	}
+
+	// function call
+	if end < len(tokens) && tokens[end].text == "(" {
+		return i, false
+	}
+
+	return end, true
+}
+
+// startsExpression returns true if the token before an operand does not make it part of a larger expression
+func startsExpression(prev token) bool {
+	switch prev.kind {
+	case tokenWord:
+		return goutil.Contains([]string{"WHERE", "ON", "HAVING", "WHEN", "AND", "OR", "NOT"}, prev.keyword())
+	case tokenPunct:
+		return prev.text == "(" || prev.text == ","
+	}
+	return false
+}
+
+// endsExpression returns true if the token after an operand does not make it part of a larger expression
+func endsExpression(tokens []token, end int) bool {
+	if end >= len(tokens) {
+		return true
+	}
+
+	next := tokens[end]
+	switch next.kind {
+	case tokenWord:
+		return !goutil.Contains([]string{"COLLATE", "IS", "IN", "LIKE", "BETWEEN", "ESCAPE"}, next.keyword())
+	case tokenPunct:
+		return next.text != "." && next.text != "("
+	case tokenComment:
+		return true
+	}
+	return false
+}
+
+func isLiteral(tokens []token) bool {
+	if len(tokens) != 1 {
+		return false
+	}
+	if tokens[0].kind == tokenString || tokens[0].kind == tokenNumber {
+		return true
+	}
+	kw := tokens[0].keyword()
+	return kw == "TRUE" || kw == "FALSE"
+}
+
+// sameOperand returns true if two operands refer to the same column
+func sameOperand(a []token, b []token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].kind == tokenParam || b[i].kind == tokenParam {
+			return false
+		}
+		if !strings.EqualFold(a[i].value(), b[i].value()) {
+			return false
+		}
+	}
+	return true
+}
+
+// literalNumber returns the numeric value of a literal
+func literalNumber(tok token) (float64, bool) {
+	switch tok.keyword() {
+	case "TRUE":
+		return 1, true
+	case "FALSE":
+		return 0, true
+	}
+
+	if tok.kind != tokenNumber && tok.kind != tokenString {
+		return 0, false
+	}
+
+	num, err := strconv.ParseFloat(strings.TrimSpace(tok.value()), 64)
+	if err != nil {
+		if n, err := strconv.ParseInt(tok.value(), 0, 64); err == nil {
+			return float64(n), true
+		}
+		return 0, false
+	}
+	return num, true
+}
+
+// compareLiterals evaluates a comparison between two literals
+//
+// mysql compares strings without case, and converts strings to numbers,
+// so the comparison is evaluated the same way to catch its tautologies.
+func compareLiterals(a token, b token, op string) bool {
+	if op == "LIKE" {
+		pattern := b.value()
+		if strings.Trim(pattern, "%") == "" && pattern != "" {
+			return true
+		}
+		return !strings.ContainsAny(pattern, "%_") && strings.EqualFold(a.value(), pattern)
+	}
+
+	cmp := 0
+	numA, okA := literalNumber(a)
+	numB, okB := literalNumber(b)
+	if okA && okB && (a.kind != tokenString || b.kind != tokenString) {
+		if numA < numB {
+			cmp = -1
+		} else if numA > numB {
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(a.value()), strings.ToLower(b.value()))
+	}
+
+	switch op {
+	case "=", "==", "<=>":
+		return cmp == 0
+	case "<>", "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// sqlKeywords are reserved words, which can not be used as a simple operand
+var sqlKeywords = []string{
+	"ALL", "AND", "ANY", "AS", "ASC", "BETWEEN", "BY", "CASE", "CAST", "DELETE", "DESC", "DISTINCT",
+	"ELSE", "END", "EXISTS", "FROM", "GROUP", "HAVING", "IN", "INSERT", "INTO", "IS", "JOIN", "LIKE",
+	"LIMIT", "NOT", "NULL", "ON", "OR", "ORDER", "SELECT", "SET", "THEN", "UNION", "UPDATE", "VALUES",
+	"WHEN", "WHERE",
+}
+
+// check runs a custom safety check on a query
+func (rule customRule) check(query string, tokens []token) *UnsafeQueryError {
+	if rule.cb != nil {
+		if !rule.cb(query) {
+			return &UnsafeQueryError{Rule: rule.name, Message: rule.message, Fragment: query}
+		}
+		return nil
+	}
+
+	offset := 0
+	if rule.where {
+		where := whereClause(query, tokens)
+		if where == "" {
+			return nil
+		}
+		offset = len(query) - len(where)
+	}
+
+	if ind := rule.re.RE.FindIndex([]byte(query[offset:]), 0); ind != nil {
+		return &UnsafeQueryError{
+			Rule:     rule.name,
+			Message:  rule.message,
+			Fragment: query[offset+ind[0] : offset+ind[1]],
+			Pos:      offset + ind[0],
+		}
+	}
+	return nil
+}
@@ -1,5 +1,11 @@
 package gosql
 
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
 //todo: add methods for `CREATE INDEX` and `DROP INDEX`: https://www.w3schools.com/sql/sql_create_index.asp
 
 // Set will INSERT or UPDATE values FROM table
@@ -11,125 +17,78 @@ package gosql
 // if nothing is found, it will use INSERT.
 //
 // If no unique args or where query exists, this method will default to INSERT.
+//
+// Keys are written in the order of the table schema when it is known
+// (see `db.Table`), followed by any other keys in alphabetical order.
+// Use `SetValues` to choose the order yourself.
 func (query *Query) Set(values map[string]any, unique ...string) error {
+	return query.SetValues(query.OrderValues(values), unique...)
+}
+
+// SetValues will INSERT or UPDATE an ordered list of values FROM table
+//
+// This method works the same as `Set`, but keeps the order of the values.
+func (query *Query) SetValues(values Values, unique ...string) error {
 	if len(values) == 0 {
 		return nil
 	}
 
-	valList := []any{}
+	if query.db.remote != nil {
+		op := OpInsert
+		q, args := query.InsertSQL(values)
+		if query.where != "" {
+			op = OpUpdate
+			q, args = query.UpdateSQL(values)
+		}
+		return query.runRemote(op, q, args, &Statement{Method: MethodSet, Keys: unique, Values: values}, nil)
+	}
 
 	// UPDATE if where query
 	if query.where != "" {
-		q := `UPDATE ` + query.table + ` SET `
-		for key, val := range values {
-			q += toAlphaNumeric(key) + ` = ?, `
-			valList = append(valList, val)
-		}
-		q = q[:len(q)-2]
-
-		q += ` ` + query.where
-		valList = append(valList, query.whereValue...)
-
-		st, err := query.db.Prepare(q)
-		if err != nil {
-			return err
-		}
-
-		_, err = st.Exec(valList...)
-		return err
+		q, args := query.UpdateSQL(values)
+		return query.exec(OpUpdate, q, args...)
 	}
 
 	// UPDATE if unique keys found with matching values
 	if len(unique) != 0 {
-		where := `WHERE `
-		whereValue := []any{}
-
-		// build where query
-		hasVal := false
-		for _, key := range unique {
-			key = toAlphaNumeric(key)
-			if val, ok := values[key]; ok {
-				if hasVal {
-					where += ` AND `
-				}
-				hasVal = true
-				where += key + ` = ?`
-				whereValue = append(whereValue, val)
-			}
-		}
-
-		// check if table contains existing rows
-		if hasVal {
-			if rows, err := query.db.Query(`SELECT * FROM `+query.table+` `+where, whereValue...); err == nil && rows.Next() {
-				rows.Close()
-
-				// UPDATE values in existing rows
-				q := `UPDATE ` + query.table + ` SET `
-				for key, val := range values {
-					q += toAlphaNumeric(key) + ` = ?, `
-					valList = append(valList, val)
-				}
-				q = q[:len(q)-2]
-
-				q += ` ` + where
-				valList = append(valList, whereValue...)
-
-				st, err := query.db.Prepare(q)
-				if err != nil {
-					return err
-				}
-
-				_, err = st.Exec(valList...)
-				return err
+		if match, ok := query.matching(values, unique); ok {
+			// check if table contains existing rows
+			// (on the primary, since a replica may not have the latest rows)
+			found := false
+			q, args := match.SelectSQL(nil)
+			err := query.Primary().each(OpSelect, q, args, func(rows *sql.Rows) bool {
+				found = true
+				return false
+			})
+
+			// UPDATE values in existing rows
+			if err == nil && found {
+				q, args := match.UpdateSQL(values)
+				return query.exec(OpUpdate, q, args...)
 			}
 		}
 	}
 
 	// INSERT values into table
-	qKey := ``
-	qVal := ``
-	for key, val := range values {
-		qKey += toAlphaNumeric(key) + `, `
-		qVal += `?, `
-		valList = append(valList, val)
-	}
-	qKey = qKey[:len(qKey)-2]
-	qVal = qVal[:len(qVal)-2]
-
-	st, err := query.db.Prepare(`INSERT INTO ` + query.table + ` (` + qKey + `) VALUES (` + qVal + `)`)
-	if err != nil {
-		return err
-	}
-	st.Exec(valList...)
-
-	return nil
+	q, args := query.InsertSQL(values)
+	return query.exec(OpInsert, q, args...)
 }
 
 // Delete will remove a row from the database table
 //
 // ! Warning: setting @force to true, will allow the database to delete all rows from a table, if its missing a `where` query
 func (query *Query) Delete(force ...bool) error {
-	if query.where == "" {
-		if len(force) != 0 && force[0] {
-			st, err := query.db.Prepare(`DELETE FROM ` + query.table)
-			if err != nil {
-				return err
-			}
-			st.Exec()
-
-			return nil
-		}
-
+	if query.where == "" && (len(force) == 0 || !force[0]) {
 		return Error_UnsafeQuery
 	}
 
-	st, err := query.db.Prepare(`DELETE FROM ` + query.table + ` ` + query.where)
-	if err != nil {
-		return err
+	q, args := query.DeleteSQL()
+
+	if query.db.remote != nil {
+		return query.runRemote(OpDelete, q, args, &Statement{Method: MethodDelete, Force: len(force) != 0 && force[0]}, nil)
 	}
-	st.Exec(query.whereValue...)
 
-	return nil
+	return query.exec(OpDelete, q, args...)
 }
 
 // Drop will drop an entire table from the database, deleting everything
@@ -140,13 +99,26 @@ func (query *Query) Drop(force bool) error {
 		return Error_UnsafeQuery
 	}
 
-	// Note: query.db.SQL will bypass the default safety checks,
+	if query.db.remote != nil {
+		return errors.New("drop is not supported by a remote database")
+	}
+
+	// Note: this query will bypass the default safety checks,
 	// since the `DROP` keyword will be denied by safety checks.
-	st, err := query.db.SQL.Prepare(`DROP TABLE ` + query.table)
+	// The statement runs once, so it is not kept in the statement cache.
+	info := query.info(OpDrop, `DROP TABLE `+query.table, nil)
+	info.Unsafe = true
+
+	err := query.db.run(context.Background(), info, func() (sql.Result, error) {
+		if query.tx != nil {
+			return query.tx.SQL.Exec(info.SQL)
+		}
+		return query.db.SQL.Exec(info.SQL)
+	})
 	if err != nil {
 		return err
 	}
-	st.Exec()
 
+	query.db.tables.remove(query.table)
 	return nil
 }
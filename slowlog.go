@@ -0,0 +1,296 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlowQuery is a record of a statement that exceeded the slow query threshold
+type SlowQuery struct {
+	Time     time.Time
+	SQL      string
+	Args     []any
+	Table    string
+	Op       Op
+	Duration time.Duration
+
+	// Rows is the number of rows read by the query builder
+	Rows int
+
+	// Err is the error returned by the statement, if any
+	Err error
+
+	// Plan is the output of the dialects `EXPLAIN` statement, one line per row
+	// (only captured if enabled)
+	Plan []string
+}
+
+// SlowQuerySink receives the records of the slow query log
+type SlowQuerySink interface {
+	LogSlowQuery(ctx context.Context, record *SlowQuery)
+}
+
+// SlowQuerySinkFunc is a SlowQuerySink built from a callback
+type SlowQuerySinkFunc func(ctx context.Context, record *SlowQuery)
+
+// LogSlowQuery calls the callback
+func (sink SlowQuerySinkFunc) LogSlowQuery(ctx context.Context, record *SlowQuery) {
+	sink(ctx, record)
+}
+
+// SlowQueryLog records statements that take longer than the threshold to run
+//
+// @explain: if true, the `EXPLAIN` (mysql) or `EXPLAIN QUERY PLAN` (sqlite)
+// output of each slow statement will be captured in the record.
+//
+// Note: the plan is only captured for statements built by the query builder,
+// since raw queries may still hold their connection while the hooks run.
+func (db *DB) SlowQueryLog(threshold time.Duration, sink SlowQuerySink, explain ...bool) {
+	db.Use(&slowQueryHook{
+		db:        db,
+		threshold: threshold,
+		sink:      sink,
+		explain:   len(explain) != 0 && explain[0],
+	})
+}
+
+// slowQueryHook is the Hook behind `db.SlowQueryLog`
+type slowQueryHook struct {
+	db        *DB
+	threshold time.Duration
+	sink      SlowQuerySink
+	explain   bool
+}
+
+func (hook *slowQueryHook) Before(ctx context.Context, info *QueryInfo) error {
+	return nil
+}
+
+func (hook *slowQueryHook) After(ctx context.Context, info *QueryInfo, result sql.Result, err error, duration time.Duration) {
+	if duration < hook.threshold || duration == 0 {
+		return
+	}
+
+	// do not log the inserts of a table sink back into itself
+	if sink, ok := hook.sink.(*slowQueryTable); ok && sink.table.table == info.Table {
+		return
+	}
+
+	record := &SlowQuery{
+		Time:     time.Now().Add(-duration),
+		SQL:      info.SQL,
+		Args:     info.Args,
+		Table:    info.Table,
+		Op:       info.Op,
+		Duration: duration,
+		Rows:     info.Rows,
+		Err:      err,
+	}
+
+	if hook.explain && err == nil {
+		switch info.Op {
+		case OpSelect, OpInsert, OpUpdate, OpDelete:
+			record.Plan, _ = hook.db.explain(ctx, info)
+		}
+	}
+
+	hook.sink.LogSlowQuery(ctx, record)
+}
+
+// explain returns the query plan of a statement, one line per row
+func (db *DB) explain(ctx context.Context, info *QueryInfo) ([]string, error) {
+	q := `EXPLAIN ` + info.SQL
+	if db.dialect == SQLite {
+		q = `EXPLAIN QUERY PLAN ` + info.SQL
+	}
+
+	var rows *sql.Rows
+	var err error
+	if info.tx != nil {
+		rows, err = info.tx.SQL.QueryContext(ctx, q, info.Args...)
+	} else {
+		rows, err = db.SQL.QueryContext(ctx, q, info.Args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := []string{}
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		dest := make([]any, len(cols))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		line := []string{}
+		for i, col := range cols {
+			if vals[i] == nil {
+				continue
+			}
+
+			// sqlite puts the readable plan in the detail column
+			if db.dialect == SQLite && col == "detail" {
+				line = []string{toString(vals[i])}
+				break
+			}
+
+			line = append(line, col+"="+toString(vals[i]))
+		}
+		plan = append(plan, strings.Join(line, " "))
+	}
+
+	return plan, rows.Err()
+}
+
+func toString(val any) string {
+	if b, ok := val.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(val)
+}
+
+// SlowQuerySlog returns a sink that writes slow queries to a structured logger
+func SlowQuerySlog(logger *slog.Logger) SlowQuerySink {
+	return SlowQuerySinkFunc(func(ctx context.Context, record *SlowQuery) {
+		attrs := []any{
+			slog.String("sql", record.SQL),
+			slog.String("table", record.Table),
+			slog.String("op", string(record.Op)),
+			slog.Duration("duration", record.Duration),
+			slog.Int("rows", record.Rows),
+		}
+
+		if len(record.Plan) != 0 {
+			attrs = append(attrs, slog.Any("plan", record.Plan))
+		}
+
+		if record.Err != nil {
+			attrs = append(attrs, slog.String("error", record.Err.Error()))
+		}
+
+		logger.WarnContext(ctx, "slow query", attrs...)
+	})
+}
+
+// slowQueryQueueSize is the number of records a table sink can hold, before new records are dropped
+const slowQueryQueueSize = 256
+
+// slowQueryTable is a sink that writes slow queries to a database table
+type slowQueryTable struct {
+	table   *Query
+	onError func(err error)
+
+	// mu guards closed, so no record is sent once the queue is closed
+	mu      sync.RWMutex
+	closed  bool
+	records chan Values
+	done    chan struct{}
+}
+
+// SlowQueryTable returns a sink that writes slow queries to a database table
+//
+// The table will be created if it does not exist.
+//
+// Note: records are written in the background by a single writer, so a slow statement
+// inside a transaction does not have to wait for its own connection.
+// If the writer falls too far behind, new records are dropped.
+// The remaining records are written when `db.Close` is called.
+//
+// @onError: called when a record could not be written, or was dropped
+// (default: logs the error with `slog.Default()`)
+func SlowQueryTable(db *DB, name string, onError ...func(err error)) SlowQuerySink {
+	sink := &slowQueryTable{
+		onError: func(err error) {
+			slog.Default().Error("slow query log", slog.String("table", name), slog.String("error", err.Error()))
+		},
+		records: make(chan Values, slowQueryQueueSize),
+		done:    make(chan struct{}),
+	}
+	if len(onError) != 0 && onError[0] != nil {
+		sink.onError = onError[0]
+	}
+
+	sink.table = db.Table(name,
+		DATETIME("time"),
+		TEXT("op"),
+		TEXT("tbl"),
+		TEXT("query"),
+		BIGINT("duration_ms"),
+		INT("rows"),
+		TEXT("plan"),
+		TEXT("error"),
+	)
+
+	go sink.write()
+	db.closers.add(sink.close)
+	return sink
+}
+
+// write runs the records in the queue, until the queue is closed
+func (sink *slowQueryTable) write() {
+	defer close(sink.done)
+
+	for values := range sink.records {
+		if err := sink.table.SetValues(values); err != nil {
+			sink.onError(err)
+		}
+	}
+}
+
+// close stops the queue, and waits for the remaining records to be written
+func (sink *slowQueryTable) close() {
+	sink.mu.Lock()
+	if !sink.closed {
+		sink.closed = true
+		close(sink.records)
+	}
+	sink.mu.Unlock()
+
+	<-sink.done
+}
+
+func (sink *slowQueryTable) LogSlowQuery(ctx context.Context, record *SlowQuery) {
+	values := Values{}.
+		Add("time", record.Time.UTC()).
+		Add("op", string(record.Op)).
+		Add("tbl", record.Table).
+		Add("query", record.SQL).
+		Add("duration_ms", record.Duration.Milliseconds()).
+		Add("rows", record.Rows).
+		Add("plan", strings.Join(record.Plan, "\n"))
+
+	if record.Err != nil {
+		values = values.Add("error", record.Err.Error())
+	}
+
+	sink.mu.RLock()
+	defer sink.mu.RUnlock()
+
+	if sink.closed {
+		sink.onError(errors.New("slow query log is closed, record dropped"))
+		return
+	}
+
+	select {
+	case sink.records <- values:
+	default:
+		sink.onError(errors.New("slow query log is full, record dropped"))
+	}
+}
@@ -1,98 +1,168 @@
 package gosql
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
-	"fmt"
-	"strings"
-	"time"
+	"sync"
 
-	"github.com/tkdeng/goregex"
-	"github.com/tkdeng/goutil"
+	"github.com/go-sql-driver/mysql"
 )
-type DB struct {
-	SQL        *sql.DB
-	initTables []string
-	unsafe     bool
-}
 
-type Server struct {
-	username string
-	password string
-	host     string
-	port     uint16
-	protocol string
-	database string
+type DB struct {
+	SQL      *sql.DB
+	unsafe   bool
+	readOnly bool
+
+	dialect Dialect
+	tables  *tableList
+	stmts   *stmtCache
+	hooks   *hookList
+	policy  *SafetyPolicy
+	cluster *cluster
+	remote  Remote
+	retry   *RetryPolicy
+
+	// keepAlive is a connection outside of the pool, which keeps a memory database open
+	keepAlive driver.Conn
+
+	// closers run before the database is closed
+	closers *closerList
 }
 
 var Error_UnsafeQuery = errors.New("unsafe query")
-
-var querySafetyChecks []func(query string) bool = []func(query string) bool{}
-var querySafetyChecksRE []*regex.Regexp = []*regex.Regexp{}
-var querySafetyChecksWhereRE []*regex.Regexp = []*regex.Regexp{}
+var Error_ReadOnly = errors.New("database is read only")
 
 // Open opens a new database
-func Open[T interface{ string | Server }](driverName string, dns T) (*DB, error) {
-	//todo: add support for sql auth and cloudflare D1 or R2
+//
+// @dns: the path of an sqlite file ("" for a new memory database, see `OpenMemory`),
+// or a `Server` to connect to with the mysql driver (driverName must be "mysql")
+// (see `OpenD1` for cloudflare D1)
+//
+// @opts: optional settings, like `ReadOnly()`, `MaxOpenConns(n)` or `LazyConnect()`
+func Open[T interface{ string | Server }](driverName string, dns T, opts ...Option) (*DB, error) {
+	o := newOptions(opts)
+	if o.err != nil {
+		return nil, o.err
+	}
 
 	var dnsVal interface{} = dns
 
-	var dbDNS string
 	if path, ok := dnsVal.(string); ok {
-		if path == "" {
-			dbDNS = "file::memory:?cache=shared"
-		} else {
-			path = string(regex.Comp(`[^\w_\-:\\/@$#!+~\.\,\s ]`).RepStrLit([]byte(path), []byte{}))
-			dbDNS = "file:" + path + "?cache=shared"
+		if o.readers > 0 && path != "" {
+			return openReaderPool(driverName, path, o)
 		}
+		return openSQLite(driverName, path, o)
 	} else if server, ok := dnsVal.(Server); ok {
-		if server.protocol == "" {
-			server.protocol = "tcp"
+		// a server is always opened with the mysql connector
+		if driverName != "mysql" {
+			return nil, errors.New("driver " + driverName + " is not supported by a server database, use \"mysql\"")
 		}
-		dbDNS = fmt.Sprintf("%s:%s@%s(%s:%d)", server.username, server.password, server.protocol, server.host, server.port)
-		if server.database != "" {
-			dbDNS += "/" + server.database
+
+		if len(o.pragmas) != 0 || o.readers > 0 {
+			return nil, errors.New("sqlite options are not supported by a server database")
 		}
-	} else {
-		return nil, errors.New("invalid dns")
-	}
 
-	db, err := sql.Open(driverName, dbDNS)
-	if err != nil {
-		return nil, err
-	}
+		cfg, err := server.Config()
+		if err != nil {
+			return nil, err
+		}
 
-	if strings.HasPrefix(dbDNS, "file:") || dbDNS == ":memory:" {
-		db.SetMaxOpenConns(1)
-	} else {
-		db.SetConnMaxLifetime(time.Minute * 3)
-		db.SetMaxOpenConns(10)
-		db.SetMaxIdleConns(10)
+		connector, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return newDB(sql.OpenDB(connector), MySQL, o, false)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	return nil, errors.New("invalid dns")
+}
+
+// newDB applies the options to an open database, and checks the connection
+//
+// @file: true for sqlite files, which default to a single connection
+func newDB(db *sql.DB, dialect Dialect, o *options, file bool) (*DB, error) {
+	o.pool(db, file)
+
+	if !o.lazy {
+		if err := o.ping(db); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
 	return &DB{
-		SQL:        db,
-		initTables: []string{},
+		SQL:      db,
+		readOnly: o.readOnly,
+		dialect:  dialect,
+		tables:   newTableList(),
+		stmts:    newStmtCache(DefaultStmtCacheSize),
+		hooks:    &hookList{},
+		policy:   NewSafetyPolicy(true),
+		retry:    o.retry,
+		closers:  &closerList{},
 	}, nil
 }
 
-// Close closes the database
+// Close closes the database, and any cached prepared statements
+//
+// If the database is a cluster, its replicas will also be closed.
 func (db *DB) Close() {
+	db.closers.run()
+	db.stmts.close()
 	db.SQL.Close()
+
+	if db.cluster != nil {
+		db.cluster.closeReplicas()
+	}
+
+	if db.keepAlive != nil {
+		db.keepAlive.Close()
+	}
+}
+
+// closerList holds the functions that run before a database is closed,
+// like the writer of a slow query table
+type closerList struct {
+	mu      sync.Mutex
+	closers []func()
+}
+
+// add registers a function to run before the database is closed
+func (list *closerList) add(fn func()) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	list.closers = append(list.closers, fn)
+}
+
+// run calls every registered function once
+func (list *closerList) run() {
+	list.mu.Lock()
+	closers := list.closers
+	list.closers = nil
+	list.mu.Unlock()
+
+	for _, fn := range closers {
+		fn()
+	}
 }
 
 // Table selects a database table
 //
 // if any rows are specified, this method will create a table if it does not exist
+//
+// If the table can not be created (like on a read only database), every statement
+// of the returned query will return the error (like `Error_ReadOnly`).
 func (db *DB) Table(name string, rows ...*DataType) *Query {
 	name = toAlphaNumeric(name)
+	var createErr error
 
-	if len(rows) != 0 && !goutil.Contains(db.initTables, name) {
+	// a remote server creates its own tables, so only the column order is kept
+	if len(rows) != 0 && db.remote != nil {
+		db.tables.add(name, rows)
+	} else if len(rows) != 0 && !db.tables.has(name) {
 		query := `CREATE TABLE IF NOT EXISTS ` + name + ` (`
 		for i, row := range rows {
 			query += row.key
@@ -107,16 +177,20 @@ func (db *DB) Table(name string, rows ...*DataType) *Query {
 		}
 		query += `)`
 
-		if st, err := db.SQL.Prepare(query); err == nil {
-			if _, err = st.Exec(); err == nil {
-				db.initTables = append(db.initTables, name)
-			}
+		info := &QueryInfo{SQL: query, Table: name, Op: OpCreate, Unsafe: true}
+		err := db.run(context.Background(), info, func() (sql.Result, error) {
+			return db.SQL.Exec(query)
+		})
+		if err == nil {
+			db.tables.add(name, rows)
 		}
+		createErr = err
 	}
 
 	return &Query{
 		db:    db,
 		table: name,
+		err:   createErr,
 	}
 }
 
@@ -129,12 +203,19 @@ func (db *DB) Table(name string, rows ...*DataType) *Query {
 //
 // To use this method, you must pass the confirm argument as "I Know What Im Doing!",
 // to confirm that you have read the documentation, and know what you are doing.
-func (db DB) Unsafe(confirm string) *DB {
+//
+// The new instance shares its tables, statement cache, hooks and safety policy
+// with the original database, but every query it runs will skip the safety checks.
+//
+// Deprecated: use `query.AllowUnsafe`, which only skips the named rules for a single query,
+// and reports the reason to hooks.
+func (db *DB) Unsafe(confirm string) *DB {
+	unsafe := *db
 	if confirm == "I Know What Im Doing!" {
-		db.unsafe = true
+		unsafe.unsafe = true
 	}
 
-	return &db
+	return &unsafe
 }
 
 // Query executes a query that returns rows, typically a SELECT.
@@ -143,10 +224,20 @@ func (db DB) Unsafe(confirm string) *DB {
 // Query uses [context.Background] internally; to specify the context, use
 // [DB.QueryContext].
 func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
-	if !db.unsafe && !SafeQuery(query) {
-		return nil, Error_UnsafeQuery
-	}
-	return db.SQL.Query(query, args...)
+	var rows *sql.Rows
+	err := db.run(context.Background(), db.rawInfo(query, args), func() (res sql.Result, err error) {
+		if db.retry == nil || !readingStatement(query, db.dialect) {
+			rows, err = db.SQL.Query(query, args...)
+			return nil, err
+		}
+
+		// reads are retried, since no rows were read yet
+		return nil, db.retry.do(context.Background(), func() error {
+			rows, err = db.SQL.Query(query, args...)
+			return err
+		})
+	})
+	return rows, err
 }
 
 // Exec executes a query without returning any rows.
@@ -155,10 +246,16 @@ func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
 // Exec uses [context.Background] internally; to specify the context, use
 // [DB.ExecContext].
 func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
-	if !db.unsafe && !SafeQuery(query) {
-		return nil, Error_UnsafeQuery
-	}
-	return db.SQL.Exec(query, args...)
+	info := db.rawInfo(query, args)
+	info.write = true
+
+	var res sql.Result
+	err := db.run(context.Background(), info, func() (sql.Result, error) {
+		var err error
+		res, err = db.SQL.Exec(query, args...)
+		return res, err
+	})
+	return res, err
 }
 
 // Prepare creates a prepared statement for later queries or executions.
@@ -170,98 +267,20 @@ func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
 // Prepare uses [context.Background] internally; to specify the context, use
 // [DB.PrepareContext].
 func (db *DB) Prepare(query string) (*sql.Stmt, error) {
-	if !db.unsafe && !SafeQuery(query) {
-		return nil, Error_UnsafeQuery
-	}
-	return db.SQL.Prepare(query)
-}
-
-// SafeQuery checks a query for common safety errors
-//
-// Note: this safety check does Not guarantee safety, and should Not be relied on.
-//
-// This method is called by default, unless `db.SQL` is used to access raw sql from
-// the default `database/sql` module.
-func SafeQuery(query string) bool {
-	if strings.TrimSpace(query) == "" {
-		return false
-	}
-
-	// check for bad keywords
-	if regex.Comp(`(?is)(DROP|;)`).Match([]byte(query)) {
-		return false
-	}
-
-	// check for bad where query
-	safe := true
-	regex.Comp(`WHERE(.*)$`).RepFunc([]byte(query), func(data func(int) []byte) []byte {
-		q := data(1)
-
-		// common sql injection: username = * AND password = *
-		if regex.Comp(`(?is)["'\']?(user(name|id|)|pass(word|)|u*id)["'\']?\s*=\s*["'\']?\*["'\']?`).Match(q) {
-			safe = false
-			return nil
-		}
-
-		// common sql injection: 1=1
-		regex.Comp(`(?is)["'\']?([\w_\-]*)["'\']?\s*=\s*["'\']?([\w_\-]*)["'\']?`).RepFunc(q, func(data func(int) []byte) []byte {
-			if bytes.Equal(data(1), data(2)) {
-				safe = false
-			}
-			return []byte{}
-		})
-
-		// check custom where regex list
-		for _, reg := range querySafetyChecksWhereRE {
-			if reg.Match([]byte(query)) {
-				safe = false
-				break
-			}
-		}
-
-		return []byte{}
+	var st *sql.Stmt
+	err := db.run(context.Background(), db.rawInfo(query, nil), func() (res sql.Result, err error) {
+		st, err = db.SQL.Prepare(query)
+		return nil, err
 	})
-	if !safe {
-		return false
-	}
-
-	// check custom regex list
-	for _, reg := range querySafetyChecksRE {
-		if reg.Match([]byte(query)) {
-			return false
-		}
-	}
-
-	// check custom callback list
-	for _, cb := range querySafetyChecks {
-		if !cb(query) {
-			return false
-		}
-	}
-
-	return true
+	return st, err
 }
 
-// AddSafetyCheck adds another safety check to the SafeQuery method
-//
-// return false, if you think the query looks unsafe.
-// return true, to continue down the safety check list.
-func AddSafetyCheck(cb func(query string) bool) {
-	querySafetyChecks = append(querySafetyChecks, cb)
-}
-
-// AddSafetyCheckRE adds another safety check to the SafeQuery method
-//
-// The `RE` stands for RegExp, so you can simply pass a regex string,
-// which will check for a match, instead of a full callback method.
-//
-// If the query matches this regex, the query will be seen as unsafe.
-//
-// @where: if true, will only check after the WHERE keyword
-func AddSafetyCheckRE(re string, where ...bool) {
-	if len(where) != 0 && where[0] {
-		querySafetyChecksWhereRE = append(querySafetyChecksWhereRE, regex.Comp(re))
-	} else {
-		querySafetyChecksRE = append(querySafetyChecksRE, regex.Comp(re))
+// rawInfo describes a raw sql query
+func (db *DB) rawInfo(query string, args []any) *QueryInfo {
+	return &QueryInfo{
+		SQL:    query,
+		Args:   args,
+		Op:     OpRaw,
+		Unsafe: db.unsafe,
 	}
 }
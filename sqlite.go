@@ -0,0 +1,270 @@
+package gosql
+
+import (
+	"database/sql"
+	"errors"
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tkdeng/goutil"
+)
+
+// SQLiteOptions are the uri params of an sqlite database
+//
+// see: https://www.sqlite.org/uri.html
+type SQLiteOptions struct {
+	// Mode is the access mode: "ro", "rw", "rwc" (default) or "memory"
+	//
+	// The `ReadOnly()` option also sets the mode to "ro".
+	Mode string
+
+	// Immutable tells sqlite the file can not change, so it is read without any locks
+	Immutable bool
+
+	// VFS is the name of the sqlite virtual file system to use
+	VFS string
+
+	// TxLock is the lock taken by `BEGIN`: "deferred" (default), "immediate" or "exclusive"
+	//
+	// "immediate" takes the write lock when a transaction begins, so a transaction
+	// will not fail with a busy error after it has started.
+	TxLock string
+
+	// Cache is the cache mode: "shared" (default) or "private"
+	Cache string
+
+	// Params are extra uri params, like the `_xxx` params of go-sqlite3
+	Params url.Values
+}
+
+// WithSQLiteOptions sets the uri params of an sqlite database
+func WithSQLiteOptions(sqlite SQLiteOptions) Option {
+	return func(opts *options) {
+		// params from `OpenURL` are kept
+		params := opts.sqlite.Params
+		opts.sqlite = sqlite
+		opts.sqlite.Params = mergeParams(params, sqlite.Params)
+	}
+}
+
+// mergeParams returns a copy of the params of a, with the params of b added to it
+func mergeParams(a url.Values, b url.Values) url.Values {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	params := url.Values{}
+	for key, val := range a {
+		params[key] = val
+	}
+	for key, val := range b {
+		params[key] = val
+	}
+	return params
+}
+
+// sqliteDSN returns the uri of an sqlite file, with the sqlite options as params
+//
+// The path is percent encoded, so any path that can be stored in a string can be opened,
+// except for paths with a NUL byte.
+func (o *options) sqliteDSN(path string) (string, error) {
+	if strings.IndexByte(path, 0) != -1 {
+		return "", errors.New("sqlite path can not contain a NUL byte: " + strconv.Quote(path))
+	}
+
+	sqlite := o.sqlite
+	values := mergeParams(nil, sqlite.Params)
+	if values == nil {
+		values = url.Values{}
+	}
+
+	if sqlite.Cache == "" {
+		sqlite.Cache = "shared"
+	}
+	if !goutil.Contains([]string{"shared", "private"}, sqlite.Cache) {
+		return "", errors.New("invalid sqlite cache mode: " + sqlite.Cache)
+	}
+	values.Set("cache", sqlite.Cache)
+
+	if o.readOnly && sqlite.Mode != "memory" {
+		sqlite.Mode = "ro"
+	}
+	if sqlite.Mode != "" {
+		if !goutil.Contains([]string{"ro", "rw", "rwc", "memory"}, sqlite.Mode) {
+			return "", errors.New("invalid sqlite mode: " + sqlite.Mode)
+		}
+		values.Set("mode", sqlite.Mode)
+	}
+
+	if sqlite.Immutable {
+		values.Set("immutable", "1")
+	}
+	if sqlite.VFS != "" {
+		values.Set("vfs", sqlite.VFS)
+	}
+
+	if sqlite.TxLock != "" {
+		if !goutil.Contains([]string{"deferred", "immediate", "exclusive"}, sqlite.TxLock) {
+			return "", errors.New("invalid sqlite txlock: " + sqlite.TxLock)
+		}
+		values.Set("_txlock", sqlite.TxLock)
+	}
+
+	// characters like `?`, `#` and `%` are escaped, so they are read as part of the path
+	escaped := (&url.URL{Path: path}).EscapedPath()
+	if strings.HasPrefix(escaped, "/") {
+		// an absolute path gets an empty authority, so `//tmp/a.db` is not read as the host "tmp"
+		return "file://" + escaped + "?" + values.Encode(), nil
+	}
+	return "file:" + escaped + "?" + values.Encode(), nil
+}
+
+// memoryDBs counts the memory databases opened without a name
+var memoryDBs atomic.Uint64
+
+// OpenMemory opens an in-memory sqlite database, with the "sqlite3" driver
+//
+// Every connection of the database shares the same memory. One connection is kept open
+// outside of the pool until `db.Close`, so the database is not destroyed when the pool
+// closes its connections.
+//
+// @name: databases opened with the same name share the same memory, until they are all closed.
+// If the name is "", every call opens a new database (same as `Open("sqlite3", "")`).
+//
+// @opts: optional settings, like `MaxOpenConns(n)` or `ForeignKeys(true)`
+func OpenMemory(name string, opts ...Option) (*DB, error) {
+	o := newOptions(opts)
+	if o.err != nil {
+		return nil, o.err
+	}
+	return openMemory("sqlite3", name, o)
+}
+
+// openMemory opens a named in-memory sqlite database
+func openMemory(driverName string, name string, o *options) (*DB, error) {
+	if name == "" {
+		name = "gosql-memory-" + strconv.FormatUint(memoryDBs.Add(1), 10)
+	}
+
+	// every connection of a memory database must share its cache
+	memory := *o
+	memory.sqlite.Mode = "memory"
+	memory.sqlite.Cache = "shared"
+
+	dbDNS, err := memory.sqliteDSN(name)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openSQLiteDSN(driverName, dbDNS, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// the memory is freed when its last connection closes
+	keepAlive, err := db.Driver().Open(dbDNS)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	gdb, err := newDB(db, dialectOf(driverName), o, true)
+	if err != nil {
+		keepAlive.Close()
+		return nil, err
+	}
+
+	gdb.keepAlive = keepAlive
+	return gdb, nil
+}
+
+// openSQLite opens an sqlite file ("" for memory)
+func openSQLite(driverName string, path string, o *options) (*DB, error) {
+	if path == "" {
+		return openMemory(driverName, "", o)
+	}
+
+	dbDNS, err := o.sqliteDSN(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openSQLiteDSN(driverName, dbDNS, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDB(db, dialectOf(driverName), o, true)
+}
+
+// openSQLiteDSN opens the connection pool of an sqlite dsn, which sets the pragmas of the options
+func openSQLiteDSN(driverName string, dbDNS string, o *options) (*sql.DB, error) {
+	if len(o.pragmas) != 0 {
+		connector, err := newPragmaConnector(driverName, dbDNS, o.pragmas)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+	}
+
+	return sql.Open(driverName, dbDNS)
+}
+
+// ReaderPool opens an sqlite file with a pool of read only connections, and a single writer
+//
+// The file is switched to the "WAL" journal mode, so readers do not wait for the writer,
+// and every connection has a private cache.
+// Reads from the query builder (`Get`, `Has` and `Count`) run on the reader pool, and every
+// other statement runs on the writer, using the same routing as `OpenCluster`.
+//
+// If the database is opened with `ReadOnly()`, only the reader pool is opened.
+// This option is ignored for memory databases.
+//
+// @readers: the number of read only connections (like `runtime.NumCPU()`)
+func ReaderPool(readers int) Option {
+	return func(opts *options) {
+		opts.readers = max(readers, 0)
+	}
+}
+
+// openReaderPool opens an sqlite file as a cluster, with a single writer and a pool of readers
+func openReaderPool(driverName string, path string, o *options) (*DB, error) {
+	// a shared cache locks tables across connections, so each connection keeps its own cache
+	pool := *o
+	pool.readers = 0
+	pool.sqlite.Cache = "private"
+
+	readerOpts := pool
+	readerOpts.readOnly = true
+	readerOpts.maxOpen = o.readers
+	readerOpts.maxIdle = o.readers
+
+	// the journal mode is set by the writer, and can not be changed by a read only connection
+	readerOpts.pragmas = maps.Clone(o.pragmas)
+	delete(readerOpts.pragmas, "journal_mode")
+
+	if o.readOnly {
+		return openSQLite(driverName, path, &readerOpts)
+	}
+
+	writerOpts := pool
+	writerOpts.maxOpen = 1
+	writerOpts.pragmas = maps.Clone(o.pragmas)
+	writerOpts.setPragma("journal_mode", "WAL")
+
+	writer, err := openSQLite(driverName, path, &writerOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := openSQLite(driverName, path, &readerOpts)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	return OpenCluster(writer, reader)
+}
@@ -0,0 +1,265 @@
+package gosql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// DefaultStmtCacheSize is the number of prepared statements a new database will keep open
+const DefaultStmtCacheSize = 64
+
+// StmtCacheStats reports the usage of the prepared statement cache
+type StmtCacheStats struct {
+	// Size is the number of statements currently cached
+	Size int
+
+	// Capacity is the maximum number of cached statements
+	Capacity int
+
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// stmtCache is an LRU cache of prepared statements, keyed by their final sql
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	lru   *list.List
+	items map[string]*list.Element
+	stats StmtCacheStats
+}
+
+type stmtCacheEntry struct {
+	query   string
+	st      *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		lru:   list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// acquire returns a prepared statement for the query, preparing it if it is not cached
+//
+// release must be called once the statement is no longer in use,
+// so an evicted statement can be closed safely.
+func (cache *stmtCache) acquire(db *sql.DB, query string) (st *sql.Stmt, release func(), err error) {
+	if st, release, ok := cache.cached(query); ok {
+		return st, release, nil
+	}
+
+	st, err = db.Prepare(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// the cache is disabled, so the statement is closed after use
+	if cache.size <= 0 {
+		entry := &stmtCacheEntry{query: query, st: st, refs: 1, evicted: true}
+		return st, func() { cache.release(entry) }, nil
+	}
+
+	// another caller may have prepared the same query in the meantime
+	if el, ok := cache.items[query]; ok {
+		st.Close()
+
+		cache.lru.MoveToFront(el)
+		entry := el.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry.st, func() { cache.release(entry) }, nil
+	}
+
+	entry := &stmtCacheEntry{query: query, st: st, refs: 1}
+	cache.items[query] = cache.lru.PushFront(entry)
+	cache.evict()
+
+	return st, func() { cache.release(entry) }, nil
+}
+
+// cached returns a prepared statement for the query, if it is cached
+//
+// A miss is counted, since the caller will prepare the statement itself.
+func (cache *stmtCache) cached(query string) (st *sql.Stmt, release func(), ok bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if el, ok := cache.items[query]; ok {
+		cache.stats.Hits++
+		cache.lru.MoveToFront(el)
+
+		entry := el.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry.st, func() { cache.release(entry) }, true
+	}
+
+	cache.stats.Misses++
+	return nil, nil, false
+}
+
+// release marks a statement as no longer in use
+func (cache *stmtCache) release(entry *stmtCacheEntry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.st.Close()
+	}
+}
+
+// evict removes the least recently used statements until the cache fits its size
+//
+// the caller must hold cache.mu
+func (cache *stmtCache) evict() {
+	for cache.lru.Len() > cache.size && cache.lru.Len() != 0 {
+		el := cache.lru.Back()
+		entry := el.Value.(*stmtCacheEntry)
+
+		cache.lru.Remove(el)
+		delete(cache.items, entry.query)
+		cache.stats.Evictions++
+
+		entry.evicted = true
+		if entry.refs == 0 {
+			entry.st.Close()
+		}
+	}
+}
+
+// resize changes the maximum number of cached statements
+func (cache *stmtCache) resize(size int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	cache.size = size
+	cache.evict()
+}
+
+// close closes every cached statement
+func (cache *stmtCache) close() {
+	cache.resize(0)
+}
+
+func (cache *stmtCache) getStats() StmtCacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Size = cache.lru.Len()
+	stats.Capacity = cache.size
+	return stats
+}
+
+// SetStmtCacheSize sets the maximum number of prepared statements kept open by the query builder
+//
+// Least recently used statements are closed when the limit is exceeded.
+// A size of 0 disables the cache, and closes every statement after it runs.
+func (db *DB) SetStmtCacheSize(size int) {
+	db.stmts.resize(size)
+}
+
+// StmtCacheStats returns the usage stats of the prepared statement cache
+func (db *DB) StmtCacheStats() StmtCacheStats {
+	return db.stmts.getStats()
+}
+
+// prepare returns a cached prepared statement for a query built by this module
+//
+// @db: the database the statement runs on (the query database, or one of its replicas)
+//
+// If the query is part of a transaction, a cached statement will be bound to it with `tx.Stmt`.
+func (query *Query) prepare(db *DB, q string) (*sql.Stmt, func(), error) {
+	if query.tx != nil {
+		// statements from tx.Stmt are closed when the transaction ends
+		if st, release, ok := db.stmts.cached(q); ok {
+			return query.tx.SQL.Stmt(st), release, nil
+		}
+
+		// a new statement is prepared on the transaction itself, since the pool
+		// may have no other free connection (like a sqlite file with a single connection)
+		st, err := query.tx.SQL.Prepare(q)
+		return st, func() {}, err
+	}
+
+	return db.stmts.acquire(db.SQL, q)
+}
+
+// exec runs a statement that does not return rows
+func (query *Query) exec(op Op, q string, args ...any) error {
+	return query.db.run(context.Background(), query.info(op, q, args), func() (sql.Result, error) {
+		st, release, err := query.prepare(query.db, q)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return st.Exec(args...)
+	})
+}
+
+// each runs a statement that returns rows, and calls cb for every row
+//
+// SELECT statements run on a replica, if the database is a cluster (see `OpenCluster`).
+//
+// @cb: return false to close the rows and break the loop
+func (query *Query) each(op Op, q string, args []any, cb func(rows *sql.Rows) bool) error {
+	info := query.info(op, q, args)
+
+	return query.db.run(context.Background(), info, func() (sql.Result, error) {
+		return nil, query.retry(info, func() error {
+			r := query.replica(op)
+			if r == nil {
+				return query.eachOn(query.db, info, cb)
+			}
+
+			start := time.Now()
+			err := query.eachOn(r.db, info, cb)
+			if err != nil && info.Rows == 0 && r.fail() {
+				// the replica is down, so read from the primary instead
+				return query.eachOn(query.db, info, cb)
+			}
+
+			r.observe(time.Since(start))
+			return err
+		})
+	})
+}
+
+// eachOn runs a statement that returns rows on a database, and calls cb for every row
+func (query *Query) eachOn(db *DB, info *QueryInfo, cb func(rows *sql.Rows) bool) error {
+	st, release, err := query.prepare(db, info.SQL)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	rows, err := st.Query(info.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		info.Rows++
+		if !cb(rows) {
+			break
+		}
+	}
+
+	return rows.Err()
+}
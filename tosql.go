@@ -0,0 +1,151 @@
+package gosql
+
+import (
+	"strings"
+)
+
+// SelectSQL returns the SELECT statement and args that `Get` will run, without running it
+func (query *Query) SelectSQL(keys []string) (string, []any) {
+	q := `SELECT `
+	if len(keys) == 0 {
+		q += `*`
+	} else {
+		for i := 0; i < len(keys); i++ {
+			q += toAlphaNumeric(keys[i])
+			if i != len(keys)-1 {
+				q += `, `
+			}
+		}
+	}
+
+	q += ` FROM ` + query.table
+
+	if query.where != "" {
+		q += ` ` + query.where
+	}
+
+	if query.order != "" {
+		q += ` ` + query.order
+	}
+
+	return q, append([]any{}, query.whereValue...)
+}
+
+// CountSQL returns the SELECT COUNT statement and args that `Count` will run, without running it
+func (query *Query) CountSQL() (string, []any) {
+	q := `SELECT COUNT(*) FROM ` + query.table
+
+	if query.where != "" {
+		q += ` ` + query.where
+	}
+
+	return q, append([]any{}, query.whereValue...)
+}
+
+// HasSQL returns the SELECT statement and args that `HasValues` will run, without running it
+//
+// If values is empty, only the where query of the query is used.
+func (query *Query) HasSQL(values Values) (string, []any) {
+	valList := []any{}
+
+	conds := []string{}
+	for _, val := range values {
+		conds = append(conds, toAlphaNumeric(val.Key)+` = ?`)
+		valList = append(valList, val.Value)
+	}
+
+	if query.where != "" {
+		where := strings.TrimSpace(strings.TrimPrefix(query.where, "WHERE"))
+		if len(conds) != 0 {
+			// the where query may use OR
+			where = `(` + where + `)`
+		}
+		conds = append(conds, where)
+		valList = append(valList, query.whereValue...)
+	}
+
+	q := `SELECT * FROM ` + query.table
+	if len(conds) != 0 {
+		q += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+
+	return q, valList
+}
+
+// InsertSQL returns the INSERT statement and args that `SetValues` will run
+// when it adds a new row, without running it
+func (query *Query) InsertSQL(values Values) (string, []any) {
+	valList := []any{}
+
+	qKey := ``
+	qVal := ``
+	for _, val := range values {
+		qKey += toAlphaNumeric(val.Key) + `, `
+		qVal += `?, `
+		valList = append(valList, val.Value)
+	}
+	if len(values) != 0 {
+		qKey = qKey[:len(qKey)-2]
+		qVal = qVal[:len(qVal)-2]
+	}
+
+	return `INSERT INTO ` + query.table + ` (` + qKey + `) VALUES (` + qVal + `)`, valList
+}
+
+// UpdateSQL returns the UPDATE statement and args that `SetValues` will run
+// when it updates existing rows, without running it
+//
+// Note: without a where query, this statement would update every row in the table.
+func (query *Query) UpdateSQL(values Values) (string, []any) {
+	valList := []any{}
+
+	q := `UPDATE ` + query.table + ` SET `
+	for _, val := range values {
+		q += toAlphaNumeric(val.Key) + ` = ?, `
+		valList = append(valList, val.Value)
+	}
+	if len(values) != 0 {
+		q = q[:len(q)-2]
+	}
+
+	if query.where != "" {
+		q += ` ` + query.where
+		valList = append(valList, query.whereValue...)
+	}
+
+	return q, valList
+}
+
+// DeleteSQL returns the DELETE statement and args that `Delete` will run, without running it
+//
+// Note: without a where query, this statement would delete every row in the table.
+func (query *Query) DeleteSQL() (string, []any) {
+	q := `DELETE FROM ` + query.table
+
+	if query.where != "" {
+		q += ` ` + query.where
+	}
+
+	return q, append([]any{}, query.whereValue...)
+}
+
+// matching returns a new query, that selects WHERE each of the keys = value
+//
+// @ok: returns false if none of the keys have a value
+func (query Query) matching(values Values, keys []string) (match *Query, ok bool) {
+	match = &Query{
+		db:    query.db,
+		tx:    query.tx,
+		table: query.table,
+	}
+
+	for _, key := range keys {
+		key = toAlphaNumeric(key)
+		if val, has := values.Get(key); has {
+			match = match.And(key).Equal(val)
+			ok = true
+		}
+	}
+
+	return match, ok
+}
@@ -0,0 +1,48 @@
+package gosql
+
+import (
+	"database/sql"
+)
+
+// Tx is an in-progress database transaction
+//
+// Queries selected with `tx.Table` will run inside the transaction,
+// and reuse the prepared statement cache of the database.
+type Tx struct {
+	db  *DB
+	SQL *sql.Tx
+}
+
+// Begin starts a transaction
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		db:  db,
+		SQL: tx,
+	}, nil
+}
+
+// Commit commits the transaction
+func (tx *Tx) Commit() error {
+	return tx.SQL.Commit()
+}
+
+// Rollback aborts the transaction
+func (tx *Tx) Rollback() error {
+	return tx.SQL.Rollback()
+}
+
+// Table selects a database table within the transaction
+//
+// Note: unlike `db.Table`, this method will not create the table
+func (tx *Tx) Table(name string) *Query {
+	return &Query{
+		db:    tx.db,
+		tx:    tx,
+		table: toAlphaNumeric(name),
+	}
+}
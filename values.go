@@ -0,0 +1,192 @@
+package gosql
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Value is a single key value pair of an ordered Values list
+type Value struct {
+	Key   string
+	Value any
+}
+
+// Values is an ordered list of key value pairs
+//
+// Unlike a map, the keys will be written to the query in the same order
+// they were added, which keeps the generated SQL stable.
+//
+//	gosql.Values{}.Add("username", "user").Add("password", "p@ssw0rd!")
+type Values []Value
+
+// Add returns a copy of the list, with a key value pair added to it
+//
+// If the key already exists, its value will be replaced, and it keeps its position.
+// The original list is never changed, so a base list can be shared by many queries.
+func (values Values) Add(key string, value any) Values {
+	list := make(Values, len(values), len(values)+1)
+	copy(list, values)
+
+	for i := range list {
+		if list[i].Key == key {
+			list[i].Value = value
+			return list
+		}
+	}
+	return append(list, Value{Key: key, Value: value})
+}
+
+// Get returns the value of a key
+func (values Values) Get(key string) (any, bool) {
+	for _, val := range values {
+		if val.Key == key {
+			return val.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Keys returns the list of keys, in order
+func (values Values) Keys() []string {
+	keys := make([]string, len(values))
+	for i, val := range values {
+		keys[i] = val.Key
+	}
+	return keys
+}
+
+// tableList keeps the column order of tables created with `db.Table`
+type tableList struct {
+	mu      sync.RWMutex
+	columns map[string][]string
+}
+
+func newTableList() *tableList {
+	return &tableList{
+		columns: map[string][]string{},
+	}
+}
+
+// has returns true if a table has already been initialized
+func (tables *tableList) has(name string) bool {
+	tables.mu.RLock()
+	defer tables.mu.RUnlock()
+
+	_, ok := tables.columns[name]
+	return ok
+}
+
+// add registers the columns of an initialized table
+func (tables *tableList) add(name string, rows []*DataType) {
+	columns := make([]string, len(rows))
+	for i, row := range rows {
+		columns[i] = row.name()
+	}
+
+	tables.set(name, columns)
+}
+
+// set registers the column names of a table
+func (tables *tableList) set(name string, columns []string) {
+	tables.mu.Lock()
+	defer tables.mu.Unlock()
+
+	tables.columns[name] = columns
+}
+
+// remove forgets a table, after it has been dropped
+func (tables *tableList) remove(name string) {
+	tables.mu.Lock()
+	defer tables.mu.Unlock()
+
+	delete(tables.columns, name)
+}
+
+// replace forgets every table, and registers the columns of another schema
+func (tables *tableList) replace(schema map[string][]string) {
+	tables.mu.Lock()
+	defer tables.mu.Unlock()
+
+	tables.columns = schema
+}
+
+// get returns the known column order of a table
+func (tables *tableList) get(name string) []string {
+	tables.mu.RLock()
+	defer tables.mu.RUnlock()
+
+	return tables.columns[name]
+}
+
+// lookup returns the columns of a table, and ignores the case of its name
+func (tables *tableList) lookup(name string) ([]string, bool) {
+	tables.mu.RLock()
+	defer tables.mu.RUnlock()
+
+	if columns, ok := tables.columns[name]; ok {
+		return columns, true
+	}
+
+	for table, columns := range tables.columns {
+		if strings.EqualFold(table, name) {
+			return columns, true
+		}
+	}
+	return nil, false
+}
+
+// clone returns a copy of the table list
+func (tables *tableList) clone() *tableList {
+	tables.mu.RLock()
+	defer tables.mu.RUnlock()
+
+	clone := newTableList()
+	for table, columns := range tables.columns {
+		clone.columns[table] = columns
+	}
+	return clone
+}
+
+// name returns the column name of a DataType
+func (dataType *DataType) name() string {
+	name, _, _ := strings.Cut(dataType.key, " ")
+	return name
+}
+
+// OrderValues converts a map into an ordered Values list, using the same order as `Set`
+//
+// Keys will be sorted in the order of the table schema when it is known,
+// and any other keys will follow in alphabetical order.
+func (query *Query) OrderValues(values map[string]any) Values {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	columns := query.db.tables.get(query.table)
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := columnIndex(columns, keys[i]), columnIndex(columns, keys[j])
+		if a != b {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+
+	list := make(Values, len(keys))
+	for i, key := range keys {
+		list[i] = Value{Key: key, Value: values[key]}
+	}
+	return list
+}
+
+// columnIndex returns the index of a column, or the length of the list if it is not found
+func columnIndex(columns []string, key string) int {
+	key = toAlphaNumeric(key)
+	for i, col := range columns {
+		if col == key {
+			return i
+		}
+	}
+	return len(columns)
+}